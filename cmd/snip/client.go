@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// client talks to the /api/v1 JSON API of a snippetbox server
+type client struct {
+	baseURL string //e.g. https://localhost:4000, no trailing slash
+	token   string //personal API token, sent as a Bearer token
+	http    *http.Client
+}
+
+func newClient(cfg config) *client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Insecure {
+		//for local servers with a self signed certificate
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &client{
+		baseURL: strings.TrimRight(cfg.URL, "/"),
+		token:   cfg.Token,
+		http:    &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}
+}
+
+// snippet is a snippet as the API returns it
+type snippet struct {
+	ID                string     `json:"id"`
+	Title             string     `json:"title"`
+	Content           string     `json:"content"`
+	Language          string     `json:"language"`
+	Visibility        string     `json:"visibility"`
+	BurnAfterReading  bool       `json:"burn_after_reading"`
+	PasswordProtected bool       `json:"password_protected"`
+	Created           time.Time  `json:"created"`
+	Updated           time.Time  `json:"updated"`
+	Expires           *time.Time `json:"expires"`
+}
+
+// newSnippet is the body of a create request, empty fields get the server defaults
+type newSnippet struct {
+	Title            string `json:"title"`
+	Content          string `json:"content"`
+	Expires          string `json:"expires,omitempty"`
+	Visibility       string `json:"visibility,omitempty"`
+	BurnAfterReading bool   `json:"burn_after_reading,omitempty"`
+	Language         string `json:"language,omitempty"`
+	Password         string `json:"password,omitempty"`
+}
+
+// apiError is an error body sent back by the server
+type apiError struct {
+	Status  int               `json:"-"`
+	Message string            `json:"error"`
+	Fields  map[string]string `json:"fields"`
+}
+
+// Error puts each validation message on its own line, sorted by field
+func (e *apiError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Message)
+
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		fmt.Fprintf(&sb, "\n  %s: %s", field, e.Fields[field])
+	}
+	return sb.String()
+}
+
+// viewURL is the page a snippet can be read on in a browser
+func (c *client) viewURL(id string) string {
+	return c.baseURL + "/snippet/view/" + url.PathEscape(id)
+}
+
+// create uploads a new snippet, returning it as the server saved it
+func (c *client) create(s newSnippet) (*snippet, error) {
+	var resp struct {
+		Snippet snippet `json:"snippet"`
+	}
+	err := c.do(http.MethodPost, "/api/v1/snippets", s, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.Snippet, nil
+}
+
+// get fetches a snippet, password is only needed for protected snippets.
+// Burn after reading snippets can't be read with a GET, the server says so
+// with a 409 and they are burnt instead, as asking for one is reading it
+func (c *client) get(id, password string) (*snippet, error) {
+	header := http.Header{}
+	if password != "" {
+		header.Set("X-Snippet-Password", password)
+	}
+
+	var resp struct {
+		Snippet snippet `json:"snippet"`
+	}
+	path := "/api/v1/snippets/" + url.PathEscape(id)
+	err := c.do(http.MethodGet, path, nil, header, &resp)
+	var e *apiError
+	if errors.As(err, &e) && e.Status == http.StatusConflict {
+		err = c.do(http.MethodPost, path+"/burn", struct{}{}, header, &resp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &resp.Snippet, nil
+}
+
+// list returns the snippets of the user the token belongs to, without their content
+func (c *client) list() ([]snippet, error) {
+	var resp struct {
+		Snippets []snippet `json:"snippets"`
+	}
+	err := c.do(http.MethodGet, "/api/v1/user/snippets", nil, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Snippets, nil
+}
+
+func (c *client) delete(id string) error {
+	return c.do(http.MethodDelete, "/api/v1/snippets/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// do sends a request to the API. body is encoded as JSON if it isn't nil, and
+// a successful response is decoded into dst if that isn't nil. Error
+// responses come back as an *apiError
+func (c *client) do(method, path string, body any, header http.Header, dst any) error {
+	var rb io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rb = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, rb)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	rs, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode >= 400 {
+		e := &apiError{Status: rs.StatusCode}
+		//anything that isn't one of our JSON errors, like a proxy error page, gets the status text
+		if json.NewDecoder(rs.Body).Decode(e) != nil || e.Message == "" {
+			e.Message = fmt.Sprintf("server responded %s", rs.Status)
+		}
+		return e
+	}
+
+	if dst == nil {
+		return nil
+	}
+	return json.NewDecoder(rs.Body).Decode(dst)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// config is where to find the server and how to log in to it
+type config struct {
+	URL      string `json:"url"`
+	Token    string `json:"token"`
+	Insecure bool   `json:"insecure"` //skip TLS certificate checks, for self signed local servers
+}
+
+// configPath is ~/.config/snip/config.json, or the equivalent on other systems
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snip", "config.json"), nil
+}
+
+// loadConfig reads the config file if there is one, then lets SNIP_URL,
+// SNIP_TOKEN and SNIP_INSECURE override it
+func loadConfig() (config, error) {
+	var cfg config
+
+	path := os.Getenv("SNIP_CONFIG")
+	if path == "" {
+		var err error
+		path, err = configPath()
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	file, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return cfg, err
+	}
+	if err == nil {
+		err = json.Unmarshal(file, &cfg)
+		if err != nil {
+			return cfg, errors.New(path + ": " + err.Error())
+		}
+	}
+
+	if v := os.Getenv("SNIP_URL"); v != "" {
+		cfg.URL = v
+	}
+	if v := os.Getenv("SNIP_TOKEN"); v != "" {
+		cfg.Token = v
+	}
+	if v := os.Getenv("SNIP_INSECURE"); v != "" {
+		cfg.Insecure = v == "1" || v == "true"
+	}
+	return cfg, nil
+}
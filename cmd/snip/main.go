@@ -0,0 +1,256 @@
+// snip is a command line client for snippetbox, it uses the JSON API
+//
+//	snip put file.go --expires 1d --private
+//	cat log | snip put
+//	snip get <id>
+//	snip ls
+//	snip rm <id>
+//
+// The server URL and API token are read from ~/.config/snip/config.json
+// ({"url": "...", "token": "..."}), or the SNIP_URL and SNIP_TOKEN
+// environment variables, which win over the file.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+const usage = `usage: snip <command> [arguments]
+
+commands:
+  put [file] [flags]    upload a file, or stdin if there is none, and print its URL
+  get <id> [flags]      print the content of a snippet
+  ls                    list your snippets
+  rm <id>               delete one of your snippets
+
+run snip <command> -h for the flags of a command
+`
+
+// languages maps file extensions to the language names the server knows,
+// anything else is left for the server to detect
+var languages = map[string]string{
+	".sh": "bash", ".c": "c", ".h": "c", ".cpp": "cpp", ".cc": "cpp", ".cs": "csharp",
+	".css": "css", ".diff": "diff", ".patch": "diff", ".go": "go", ".html": "html",
+	".java": "java", ".js": "javascript", ".json": "json", ".kt": "kotlin", ".lua": "lua",
+	".md": "markdown", ".php": "php", ".ps1": "powershell", ".py": "python", ".rb": "ruby",
+	".rs": "rust", ".sql": "sql", ".swift": "swift", ".toml": "toml", ".ts": "typescript",
+	".txt": "plaintext", ".xml": "xml", ".yaml": "yaml", ".yml": "yaml",
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run is main without the os.Exit, returning the exit code: 0 on success,
+// 1 if the server turned the request down and 2 for usage errors
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var cmd func(*client, []string, io.Reader, io.Writer) error
+	switch args[0] {
+	case "put":
+		cmd = cmdPut
+	case "get":
+		cmd = cmdGet
+	case "ls":
+		cmd = cmdList
+	case "rm":
+		cmd = cmdRemove
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "snip: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "snip: %v\n", err)
+		return 2
+	}
+	if cfg.URL == "" {
+		fmt.Fprintln(stderr, "snip: no server URL, set SNIP_URL or url in the config file")
+		return 2
+	}
+
+	err = cmd(newClient(cfg), args[1:], stdin, stdout)
+	if err != nil {
+		//the flag package has already said what was wrong
+		if errors.Is(err, errUsagePrinted) {
+			return 2
+		}
+		fmt.Fprintf(stderr, "snip: %v\n", err)
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// usageError is a mistake in the command line rather than something the server said
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// errUsagePrinted is returned by parseArgs for bad flags and -h, which the flag
+// package has already printed along with the flags of the command
+var errUsagePrinted = errors.New("usage printed")
+
+func cmdPut(c *client, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("put", flag.ContinueOnError)
+	title := fs.String("title", "", "title, defaults to the file name")
+	expires := fs.String("expires", "", "expire after 10m, 1h, 1d, 1w, 1y or never (server default 1w)")
+	private := fs.Bool("private", false, "only you can see it")
+	unlisted := fs.Bool("unlisted", false, "leave it off the home page")
+	burn := fs.Bool("burn", false, "delete it the first time it is read")
+	password := fs.String("password", "", "password needed to read it")
+	lang := fs.String("lang", "", "language for highlighting, guessed from the file extension")
+
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) > 1 {
+		return usageError("put takes at most one file")
+	}
+	if *private && *unlisted {
+		return usageError("-private and -unlisted can't both be set")
+	}
+
+	s := newSnippet{
+		Title:            *title,
+		Expires:          *expires,
+		BurnAfterReading: *burn,
+		Password:         *password,
+		Language:         *lang,
+	}
+	switch {
+	case *private:
+		s.Visibility = "private"
+	case *unlisted:
+		s.Visibility = "unlisted"
+	}
+
+	var content []byte
+	if len(files) == 1 {
+		content, err = os.ReadFile(files[0])
+		if err != nil {
+			return err
+		}
+		if s.Title == "" {
+			s.Title = filepath.Base(files[0])
+		}
+		if s.Language == "" {
+			s.Language = languages[strings.ToLower(filepath.Ext(files[0]))]
+		}
+	} else {
+		content, err = io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		if s.Title == "" {
+			s.Title = "stdin"
+		}
+	}
+	s.Content = string(content)
+
+	created, err := c.create(s)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, c.viewURL(created.ID))
+	return nil
+}
+
+func cmdGet(c *client, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	password := fs.String("password", "", "password of a protected snippet")
+
+	ids, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(ids) != 1 {
+		return usageError("get takes one snippet ID")
+	}
+
+	s, err := c.get(ids[0], *password)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(stdout, s.Content)
+	if !strings.HasSuffix(s.Content, "\n") {
+		fmt.Fprintln(stdout)
+	}
+	return nil
+}
+
+func cmdList(c *client, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("ls", flag.ContinueOnError)
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return usageError("ls takes no arguments")
+	}
+
+	snippets, err := c.list()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tVISIBILITY\tEXPIRES\tTITLE")
+	for _, s := range snippets {
+		expires := "never"
+		if s.Expires != nil {
+			expires = s.Expires.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.ID, s.Visibility, expires, s.Title)
+	}
+	return tw.Flush()
+}
+
+func cmdRemove(c *client, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("rm", flag.ContinueOnError)
+	ids, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(ids) != 1 {
+		return usageError("rm takes one snippet ID")
+	}
+	return c.delete(ids[0])
+}
+
+// parseArgs parses flags that come before or after the positional arguments,
+// so both snip put -private file.go and snip put file.go -private work. It
+// returns the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, errUsagePrinted
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
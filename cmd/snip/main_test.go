@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"snippetbox/internal/assert"
+	"strings"
+	"testing"
+)
+
+// newTestAPI fakes the parts of the snippetbox API the client uses. It only
+// accepts the token "sb_test" and records the last snippet created
+func newTestAPI(t *testing.T) (*httptest.Server, *newSnippet) {
+	created := &newSnippet{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/snippets", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sb_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"error":"invalid or expired API token"}`)
+			return
+		}
+		err := json.NewDecoder(r.Body).Decode(created)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(created.Content) == "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			io.WriteString(w, `{"error":"validation failed","fields":{"content":"This field cannot be blank either, cmon dude","expires":"Field must be a duration"}}`)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"snippet":{"id":"Nw4sE6yH0j","title":"`+created.Title+`"}}`)
+	})
+	mux.HandleFunc("GET /api/v1/snippets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "Bn9rT4uY6w" {
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, `{"error":"snippet is burn after reading"}`)
+			return
+		}
+		if r.PathValue("id") != "Xk3pQ9aZ1b" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error":"snippet not found"}`)
+			return
+		}
+		io.WriteString(w, `{"snippet":{"id":"Xk3pQ9aZ1b","content":"An old silent pond..."}}`)
+	})
+	mux.HandleFunc("POST /api/v1/snippets/{id}/burn", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"snippet":{"id":"Bn9rT4uY6w","content":"Gone in a flash"}}`)
+	})
+	mux.HandleFunc("GET /api/v1/user/snippets", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"snippets":[{"id":"Xk3pQ9aZ1b","title":"An old silent pond","visibility":"public","expires":null}]}`)
+	})
+	mux.HandleFunc("DELETE /api/v1/snippets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts, created
+}
+
+func TestRun(t *testing.T) {
+	ts, created := newTestAPI(t)
+
+	dir := t.TempDir()
+	goFile := filepath.Join(dir, "main.go")
+	err := os.WriteFile(goFile, []byte("package main\n"), 0o644)
+	assert.NilError(t, err)
+
+	t.Setenv("SNIP_CONFIG", filepath.Join(dir, "missing.json"))
+	t.Setenv("SNIP_URL", ts.URL)
+	t.Setenv("SNIP_TOKEN", "sb_test")
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+		wantTitle  string
+		wantLang   string
+	}{
+		{
+			name:       "Put file",
+			args:       []string{"put", goFile, "--expires", "1d", "--private"},
+			wantCode:   0,
+			wantStdout: ts.URL + "/snippet/view/Nw4sE6yH0j\n",
+			wantTitle:  "main.go",
+			wantLang:   "go",
+		},
+		{
+			name:       "Put stdin",
+			args:       []string{"put"},
+			stdin:      "a log line\n",
+			wantCode:   0,
+			wantStdout: ts.URL + "/snippet/view/Nw4sE6yH0j\n",
+			wantTitle:  "stdin",
+		},
+		{
+			name:       "Validation errors",
+			args:       []string{"put", "-expires", "7"},
+			stdin:      "",
+			wantCode:   1,
+			wantStderr: "snip: validation failed\n  content: This field cannot be blank either, cmon dude\n  expires: Field must be a duration\n",
+			wantTitle:  "stdin",
+		},
+		{
+			name:       "Get",
+			args:       []string{"get", "Xk3pQ9aZ1b"},
+			wantCode:   0,
+			wantStdout: "An old silent pond...\n",
+		},
+		{
+			name:       "Get burn after reading",
+			args:       []string{"get", "Bn9rT4uY6w"},
+			wantCode:   0,
+			wantStdout: "Gone in a flash\n",
+		},
+		{
+			name:       "Get missing",
+			args:       []string{"get", "Aa1Bb2Cc3D"},
+			wantCode:   1,
+			wantStderr: "snip: snippet not found\n",
+		},
+		{
+			name:       "List",
+			args:       []string{"ls"},
+			wantCode:   0,
+			wantStdout: "ID          VISIBILITY  EXPIRES  TITLE\nXk3pQ9aZ1b  public      never    An old silent pond\n",
+		},
+		{
+			name:     "Remove",
+			args:     []string{"rm", "Xk3pQ9aZ1b"},
+			wantCode: 0,
+		},
+		{
+			name:       "Remove without ID",
+			args:       []string{"rm"},
+			wantCode:   2,
+			wantStderr: "snip: rm takes one snippet ID\n",
+		},
+		{
+			name:       "Unknown command",
+			args:       []string{"cp"},
+			wantCode:   2,
+			wantStderr: `snip: unknown command "cp"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*created = newSnippet{}
+			var stdout, stderr bytes.Buffer
+
+			code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, stdout.String(), tt.wantStdout)
+			assert.StringContains(t, stderr.String(), tt.wantStderr)
+			assert.Equal(t, created.Title, tt.wantTitle)
+			assert.Equal(t, created.Language, tt.wantLang)
+		})
+	}
+}
+
+func TestRunBadToken(t *testing.T) {
+	ts, _ := newTestAPI(t)
+	t.Setenv("SNIP_CONFIG", filepath.Join(t.TempDir(), "missing.json"))
+	t.Setenv("SNIP_URL", ts.URL)
+	t.Setenv("SNIP_TOKEN", "sb_wrong")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"put"}, strings.NewReader("hello"), &stdout, &stderr)
+	assert.Equal(t, code, 1)
+	assert.Equal(t, stderr.String(), "snip: invalid or expired API token\n")
+}
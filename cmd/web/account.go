@@ -0,0 +1,431 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"snippetbox/internal/models"
+	"snippetbox/internal/validator"
+	"time"
+)
+
+// The account page has a form for each setting, they are kept together so
+// one can be shown with its errors while the others stay as they were
+type accountForms struct {
+	Name     accountNameForm
+	Email    accountEmailForm
+	Password accountPasswordForm
+}
+
+type accountNameForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
+// the current password is asked for as whoever controls the email can reset the password
+type accountEmailForm struct {
+	Email               string `form:"email"`
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+type accountPasswordForm struct {
+	CurrentPassword     string `form:"current_password"`
+	NewPassword         string `form:"new_password"`
+	validator.Validator `form:"-"`
+}
+
+// account shows the logged in user's details with forms to change them
+func (app *application) account(w http.ResponseWriter, r *http.Request) {
+	app.renderAccount(w, r, http.StatusOK, accountForms{})
+}
+
+// renderAccount shows the account page with forms, filling in the current name
+// and email for any form that isn't being shown again with errors
+func (app *application) renderAccount(w http.ResponseWriter, r *http.Request, status int, forms accountForms) {
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if forms.Name.Name == "" {
+		forms.Name.Name = user.Name
+	}
+	if forms.Email.Email == "" {
+		forms.Email.Email = user.Email
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Form = forms
+	app.render(w, status, "account.tmpl", data)
+}
+
+func (app *application) accountNamePost(w http.ResponseWriter, r *http.Request) {
+	var form accountNameForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 255), "name", "This field cannot be more than 255 characters long")
+	if !form.Valid() {
+		app.renderAccount(w, r, http.StatusUnprocessableEntity, accountForms{Name: form})
+		return
+	}
+
+	err = app.users.UpdateName(app.authenticatedUserID(r), form.Name)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "Your name has been changed")
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+// accountEmailPost changes the email, which then has to be verified again
+func (app *application) accountEmailPost(w http.ResponseWriter, r *http.Request) {
+	var form accountEmailForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	if !form.Valid() {
+		app.renderAccount(w, r, http.StatusUnprocessableEntity, accountForms{Email: form})
+		return
+	}
+
+	id := app.authenticatedUserID(r)
+	err = app.users.CheckPassword(id, form.Password)
+	if err == nil {
+		err = app.users.UpdateEmail(id, form.Email)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidCredentials):
+			form.AddFieldError("password", "Password is incorrect")
+		case errors.Is(err, models.ErrDuplicateEmail):
+			form.AddFieldError("email", "Email address is already in use")
+		default:
+			app.serverError(w, err)
+			return
+		}
+		app.renderAccount(w, r, http.StatusUnprocessableEntity, accountForms{Email: form})
+		return
+	}
+
+	// the account now answers to a different email, so treat it like logging in
+	// again and sign out anyone else who is logged in to it
+	err = app.userSessions.DeleteOthers(id, app.sessionManager.GetInt(r.Context(), "sessionID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.sendVerifyEmail(r, user)
+	if err != nil {
+		app.errorLog.Printf("sending verification email to user %d: %v", id, err)
+	}
+	app.sessionManager.Put(r.Context(), "flash", "Your email has been changed. Check it for a link to verify the new address")
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+func (app *application) accountPasswordPost(w http.ResponseWriter, r *http.Request) {
+	var form accountPasswordForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.CheckField(validator.NotBlank(form.CurrentPassword), "current_password", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.NewPassword), "new_password", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "new_password", "This field must be at least 8 characters long")
+	form.CheckField(validator.MaxBytes(form.NewPassword, maxPasswordBytes), "new_password", passwordTooLong)
+	if !form.Valid() {
+		app.renderAccount(w, r, http.StatusUnprocessableEntity, accountForms{Password: form})
+		return
+	}
+
+	id := app.authenticatedUserID(r)
+	err = app.users.CheckPassword(id, form.CurrentPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("current_password", "Password is incorrect")
+			app.renderAccount(w, r, http.StatusUnprocessableEntity, accountForms{Password: form})
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	err = app.users.UpdatePassword(id, form.NewPassword)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	//anyone else logged in with the old password is signed out
+	err = app.userSessions.DeleteOthers(id, app.sessionManager.GetInt(r.Context(), "sessionID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been changed")
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+// what happens to the snippets of a deleted account
+const (
+	deleteSnippets    = "delete"
+	anonymizeSnippets = "anonymize"
+)
+
+type accountDeleteForm struct {
+	Password            string `form:"password"`
+	Snippets            string `form:"snippets"` //deleteSnippets or anonymizeSnippets
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountDelete(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountDeleteForm{Snippets: deleteSnippets}
+	app.render(w, http.StatusOK, "close.tmpl", data)
+}
+
+// accountDeletePost deletes the account after checking the password, and logs
+// it out everywhere. Snippets are deleted or kept without an owner as chosen
+func (app *application) accountDeletePost(w http.ResponseWriter, r *http.Request) {
+	var form accountDeleteForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.Snippets, deleteSnippets, anonymizeSnippets), "snippets", "Pick what happens to your snippets")
+
+	id := app.authenticatedUserID(r)
+	if form.Valid() {
+		err = app.users.CheckPassword(id, form.Password)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, err)
+				return
+			}
+			form.AddFieldError("password", "Password is incorrect")
+		}
+	}
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "close.tmpl", data)
+		return
+	}
+
+	if form.Snippets == anonymizeSnippets {
+		_, err = app.snippets.AnonymizeByUser(id)
+	} else {
+		_, err = app.snippets.DeleteByUser(id)
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.users.Delete(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.destroyUserSessions(r.Context(), id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	//this request's session was one of them, start a fresh one for the flash
+	err = app.sessionManager.Destroy(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "Your account has been deleted")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// destroyUserSessions logs a user out on every device by deleting each session
+// in the store that belongs to them
+func (app *application) destroyUserSessions(ctx context.Context, userID int) error {
+	return app.sessionManager.Iterate(ctx, func(ctx context.Context) error {
+		if app.sessionManager.GetInt(ctx, "authenticatedUserID") != userID {
+			return nil
+		}
+		return app.sessionManager.Destroy(ctx)
+	})
+}
+
+// accountExportJSON is account.json in the data export
+type accountExportJSON struct {
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Created       time.Time `json:"created"`
+}
+
+// snippetExportJSON is a snippet in snippets.json, with every version of it
+type snippetExportJSON struct {
+	snippetJSON
+	Revisions []revisionExportJSON `json:"revisions"`
+}
+
+type revisionExportJSON struct {
+	Revision int       `json:"revision"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content"`
+	Created  time.Time `json:"created"`
+}
+
+// tokenExportJSON is a token in tokens.json. The token itself was never kept
+type tokenExportJSON struct {
+	Name     string     `json:"name"`
+	Scopes   []string   `json:"scopes"`
+	Created  time.Time  `json:"created"`
+	LastUsed *time.Time `json:"last_used"`
+	Expires  *time.Time `json:"expires"`
+}
+
+// accountExport downloads everything stored about the user as a ZIP file:
+// account.json, snippets.json, tokens.json and each snippet as a plain file
+// under snippets/
+func (app *application) accountExport(w http.ResponseWriter, r *http.Request) {
+	id := app.authenticatedUserID(r)
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	snippets, err := app.snippets.ListByUser(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	tokens, err := app.tokens.ListByUser(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	account := accountExportJSON{
+		Name:          user.Name,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Created:       user.Created.UTC(),
+	}
+	snippetList := make([]snippetExportJSON, len(snippets))
+	for i, snippet := range snippets {
+		revisions, err := app.snippets.Revisions(snippet.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		snippetList[i] = snippetExportJSON{snippetJSON: newSnippetJSON(snippet), Revisions: []revisionExportJSON{}}
+		for _, rev := range revisions {
+			snippetList[i].Revisions = append(snippetList[i].Revisions, revisionExportJSON{
+				Revision: rev.Revision,
+				Title:    rev.Title,
+				Content:  rev.Content,
+				Created:  rev.Created.UTC(),
+			})
+		}
+	}
+	tokenList := make([]tokenExportJSON, len(tokens))
+	for i, token := range tokens {
+		tokenList[i] = tokenExportJSON{
+			Name:     token.Name,
+			Scopes:   token.Scopes,
+			Created:  token.Created.UTC(),
+			LastUsed: optionalTime(token.LastUsed),
+			Expires:  optionalTime(token.Expires),
+		}
+	}
+
+	//built in memory first so a failure can still be a 500 rather than half a download
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"account.json", account},
+		{"snippets.json", snippetList},
+		{"tokens.json", tokenList},
+	}
+	for _, f := range files {
+		js, err := json.MarshalIndent(f.v, "", "  ")
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		err = writeZipFile(zw, f.name, js)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	for _, snippet := range snippets {
+		err = writeZipFile(zw, "snippets/"+snippet.ShortID+"/"+snippetFilename(snippet), []byte(snippet.Content))
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	err = zw.Close()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "snippetbox-export.zip"}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// optionalTime is nil for a zero time, so JSON gets a null rather than year 1
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"snippetbox/internal/assert"
+	"snippetbox/internal/mailer"
+	"strings"
+	"testing"
+)
+
+func TestAccount(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	ts.login(t)
+	code, _, body := ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>Alice</td>")
+	assert.StringContains(t, body, "<td>alice@example.com</td>")
+	assert.StringContains(t, body, "<input type='text' name='name' value='Alice'>")
+	assert.StringContains(t, body, "<a href='/account/tokens'>API tokens</a> keep working")
+}
+
+func TestAccountPosts(t *testing.T) {
+	app := newTestApplication(t)
+	var sent bytes.Buffer
+	app.mailer = &mailer.Log{Out: &sent}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	csrfToken := ts.csrfToken(t, "/account")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		fields   map[string]string
+		wantCode int
+		wantBody string
+		wantMail string
+	}{
+		{
+			name:     "Name",
+			urlPath:  "/account/name",
+			fields:   map[string]string{"name": "Alice Jones"},
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Blank name",
+			urlPath:  "/account/name",
+			fields:   map[string]string{"name": " "},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Email",
+			urlPath:  "/account/email",
+			fields:   map[string]string{"email": "alice@example.org", "password": "pa$$word"},
+			wantCode: http.StatusSeeOther,
+			wantMail: "/user/verify/",
+		},
+		{
+			name:     "Email taken",
+			urlPath:  "/account/email",
+			fields:   map[string]string{"email": "dupe@example.com", "password": "pa$$word"},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Email address is already in use",
+		},
+		{
+			name:     "Email with wrong password",
+			urlPath:  "/account/email",
+			fields:   map[string]string{"email": "alice@example.org", "password": "wrong"},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Password is incorrect",
+		},
+		{
+			name:     "Invalid email",
+			urlPath:  "/account/email",
+			fields:   map[string]string{"email": "alice@", "password": "pa$$word"},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be a valid email address",
+		},
+		{
+			name:     "Password",
+			urlPath:  "/account/password",
+			fields:   map[string]string{"current_password": "pa$$word", "new_password": "n3w pa$$word"},
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Wrong current password",
+			urlPath:  "/account/password",
+			fields:   map[string]string{"current_password": "wrong", "new_password": "n3w pa$$word"},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Password is incorrect",
+		},
+		{
+			name:     "Short new password",
+			urlPath:  "/account/password",
+			fields:   map[string]string{"current_password": "pa$$word", "new_password": "short"},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be at least 8 characters long",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent.Reset()
+			form := url.Values{}
+			for k, v := range tt.fields {
+				form.Add(k, v)
+			}
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+			if code == http.StatusSeeOther {
+				assert.Equal(t, header.Get("Location"), "/account")
+			}
+			if tt.wantMail != "" {
+				assert.StringContains(t, sent.String(), tt.wantMail)
+			}
+		})
+	}
+}
+
+func TestAccountSignsOutOthers(t *testing.T) {
+	tests := []struct {
+		name    string
+		urlPath string
+		fields  map[string]string
+	}{
+		{
+			name:    "Email",
+			urlPath: "/account/email",
+			fields:  map[string]string{"email": "alice@example.org", "password": "pa$$word"},
+		},
+		{
+			name:    "Password",
+			urlPath: "/account/password",
+			fields:  map[string]string{"current_password": "pa$$word", "new_password": "n3w pa$$word"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			// Log in from two browsers, each with its own cookie jar.
+			ts.login(t)
+			other := ts.Client().Jar
+			jar, err := cookiejar.New(nil)
+			assert.NilError(t, err)
+			ts.Client().Jar = jar
+			ts.login(t)
+
+			form := url.Values{}
+			for k, v := range tt.fields {
+				form.Add(k, v)
+			}
+			form.Add("csrf_token", ts.csrfToken(t, "/account"))
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, http.StatusSeeOther)
+
+			// The browser that made the change stays logged in, the other one doesn't.
+			code, _, _ = ts.get(t, "/account")
+			assert.Equal(t, code, http.StatusOK)
+
+			ts.Client().Jar = other
+			code, header, _ := ts.get(t, "/account")
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, header.Get("Location"), "/user/login")
+		})
+	}
+}
+
+func TestAccountExport(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	code, header, body := ts.get(t, "/account/export")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/zip")
+	assert.StringContains(t, header.Get("Content-Disposition"), "snippetbox-export.zip")
+
+	zr, err := zip.NewReader(strings.NewReader(body), int64(len(body)))
+	assert.NilError(t, err)
+	files := map[string]bool{}
+	for _, f := range zr.File {
+		files[f.Name] = true
+	}
+	for _, name := range []string{"account.json", "snippets.json", "tokens.json"} {
+		assert.Equal(t, files[name], true)
+	}
+
+	f, err := zr.Open("account.json")
+	assert.NilError(t, err)
+	defer f.Close()
+	var account accountExportJSON
+	err = json.NewDecoder(f).Decode(&account)
+	assert.NilError(t, err)
+	assert.Equal(t, account.Email, "alice@example.com")
+}
+
+func TestAccountDeletePost(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		snippets string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Delete snippets",
+			password: "pa$$word",
+			snippets: "delete",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Anonymize snippets",
+			password: "pa$$word",
+			snippets: "anonymize",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Wrong password",
+			password: "wrong",
+			snippets: "delete",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Password is incorrect",
+		},
+		{
+			name:     "No snippets choice",
+			password: "pa$$word",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Pick what happens to your snippets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("snippets", tt.snippets)
+			form.Add("csrf_token", ts.csrfToken(t, "/account/delete"))
+
+			code, header, body := ts.postForm(t, "/account/delete", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+			if code != http.StatusSeeOther {
+				return
+			}
+			assert.Equal(t, header.Get("Location"), "/")
+
+			//logged out, so the account page sends us to log in
+			code, header, _ = ts.get(t, "/account")
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, header.Get("Location"), "/user/login")
+		})
+	}
+}
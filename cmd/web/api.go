@@ -0,0 +1,420 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"snippetbox/internal/models"
+	"strconv"
+	"time"
+)
+
+// The JSON API under /api/v1. It uses the same models and validation as the
+// HTML pages but speaks JSON both ways, errors included
+
+// maxJSONBody caps the size of API request bodies
+const maxJSONBody = 1 << 20
+
+// errUnsupportedMediaType is returned by readJSON when the body isn't JSON
+var errUnsupportedMediaType = errors.New("request body must be application/json")
+
+// snippetJSON is how a snippet looks in API responses
+type snippetJSON struct {
+	ID                string     `json:"id"`
+	Title             string     `json:"title"`
+	Content           string     `json:"content,omitempty"` //left out of lists, see newSnippetListJSON
+	Language          string     `json:"language"`
+	Visibility        string     `json:"visibility"`
+	BurnAfterReading  bool       `json:"burn_after_reading"`
+	PasswordProtected bool       `json:"password_protected"`
+	Created           time.Time  `json:"created"`
+	Updated           time.Time  `json:"updated"`
+	Expires           *time.Time `json:"expires"` //null for snippets that never expire
+}
+
+func newSnippetJSON(snippet *models.Snippet) snippetJSON {
+	s := snippetJSON{
+		ID:                snippet.ShortID,
+		Title:             snippet.Title,
+		Content:           snippet.Content,
+		Language:          snippet.Language,
+		Visibility:        snippet.Visibility,
+		BurnAfterReading:  snippet.BurnAfterReading,
+		PasswordProtected: snippet.HasPassword(),
+		Created:           snippet.Created.UTC(),
+		Updated:           snippet.Updated.UTC(),
+	}
+	if !snippet.Expires.IsZero() {
+		expires := snippet.Expires.UTC()
+		s.Expires = &expires
+	}
+	return s
+}
+
+// newSnippetListJSON is newSnippetJSON for lists. The content is left out,
+// lists would otherwise give away password protected and burn after reading
+// snippets without the checks apiSnippetView makes
+func newSnippetListJSON(snippets []*models.Snippet) []snippetJSON {
+	list := make([]snippetJSON, len(snippets))
+	for i, snippet := range snippets {
+		list[i] = newSnippetJSON(snippet)
+		list[i].Content = ""
+	}
+	return list
+}
+
+// apiErrorJSON is the body of every API error. Fields holds the validation
+// errors, keyed the same way as on the HTML forms
+type apiErrorJSON struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// snippetPatchJSON is the body of PATCH /api/v1/snippets/:id, fields left out are not changed
+type snippetPatchJSON struct {
+	Title   *string `json:"title"`
+	Content *string `json:"content"`
+	Expires *string `json:"expires"` //only ever pushes the expiry back, like the extend button
+}
+
+// writeJSON sends v as the JSON response body
+func (app *application) writeJSON(w http.ResponseWriter, status int, v any) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+	w.Write([]byte("\n"))
+}
+
+// readJSON decodes a request body into dst. Only JSON is accepted, which also
+// keeps out cross site form posts now the API has no CSRF token, as browsers
+// won't send a JSON body to another origin without asking first
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errUnsupportedMediaType
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBody)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(dst)
+	if err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	//anything after the first value is a mistake too
+	if dec.Decode(&struct{}{}) != io.EOF {
+		return errors.New("invalid JSON body: must be a single JSON value")
+	}
+	return nil
+}
+
+// apiError sends a JSON error body with the given status
+func (app *application) apiError(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, apiErrorJSON{Error: message})
+}
+
+// apiServerError is serverError for the API
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+	app.errorLog.Output(2, trace)
+
+	//not using writeJSON, if encoding failed it would end up back here
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte(`{"error":"Internal Server Error"}` + "\n"))
+}
+
+func (app *application) apiNotFound(w http.ResponseWriter) {
+	app.apiError(w, http.StatusNotFound, "snippet not found")
+}
+
+// apiBadRequest answers a body readJSON couldn't read
+func (app *application) apiBadRequest(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUnsupportedMediaType) {
+		app.apiError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	app.apiError(w, http.StatusBadRequest, err.Error())
+}
+
+// apiValidationError sends the field errors of a form that didn't validate
+func (app *application) apiValidationError(w http.ResponseWriter, fieldErrors map[string]string) {
+	app.writeJSON(w, http.StatusUnprocessableEntity, apiErrorJSON{Error: "validation failed", Fields: fieldErrors})
+}
+
+// apiFindSnippet is findSnippet with JSON errors
+func (app *application) apiFindSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, err := app.snippetFromRequest(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return nil, false
+	}
+	return snippet, true
+}
+
+// apiOwnedSnippet is ownedSnippet with JSON errors
+func (app *application) apiOwnedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.apiFindSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !app.ownsSnippet(r, snippet) {
+		app.apiError(w, http.StatusForbidden, "only the owner can change this snippet")
+		return nil, false
+	}
+	return snippet, true
+}
+
+// GET /api/v1/snippets lists the latest public snippets
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.Latest()
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippets": newSnippetListJSON(snippets)})
+}
+
+// GET /api/v1/user/snippets lists every snippet of the logged in user
+func (app *application) apiUserSnippets(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.ListByUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippets": newSnippetListJSON(snippets)})
+}
+
+// GET /api/v1/snippets/:id returns a single snippet. API clients don't keep a
+// session to remember an unlock in, so the password of a protected snippet is
+// sent in the X-Snippet-Password header every time. Burn after reading
+// snippets get a 409, a GET can come from a link preview or a retry and
+// mustn't use up the one read, see apiSnippetBurn
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiFindSnippet(w, r)
+	if !ok {
+		return
+	}
+	if !app.apiUnlockSnippet(w, r, snippet) {
+		return
+	}
+	if snippet.BurnAfterReading {
+		app.apiError(w, http.StatusConflict, "snippet is burn after reading, POST to /api/v1/snippets/"+snippet.ShortID+"/burn to read and delete it")
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippet": newSnippetJSON(snippet)})
+}
+
+// POST /api/v1/snippets/:id/burn reads a burn after reading snippet, deleting
+// it. The body has to be {} as a JSON request is what keeps other sites from
+// burning snippets with a visitor's session. Other snippets are just returned
+func (app *application) apiSnippetBurn(w http.ResponseWriter, r *http.Request) {
+	err := app.readJSON(w, r, &struct{}{})
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+	snippet, ok := app.apiFindSnippet(w, r)
+	if !ok {
+		return
+	}
+	if !app.apiUnlockSnippet(w, r, snippet) {
+		return
+	}
+
+	if snippet.BurnAfterReading {
+		burnt, err := app.snippets.Burn(snippet.ShortID)
+		if err != nil {
+			//someone else read it first
+			if errors.Is(err, models.ErrNoRecord) {
+				app.apiNotFound(w)
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+		snippet = burnt
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippet": newSnippetJSON(snippet)})
+}
+
+// apiUnlockSnippet checks the X-Snippet-Password header of a request for a
+// password protected snippet, sending an error and returning false if it's
+// missing or wrong. Guesses share the unlock form's budget, reads can't go
+// through limitRate as most of them don't need a password
+func (app *application) apiUnlockSnippet(w http.ResponseWriter, r *http.Request, snippet *models.Snippet) bool {
+	if app.snippetUnlocked(r, snippet) {
+		return true
+	}
+
+	password := r.Header.Get("X-Snippet-Password")
+	if password == "" {
+		app.apiError(w, http.StatusUnauthorized, "snippet is password protected, send the password in the X-Snippet-Password header")
+		return false
+	}
+	if ok, wait := app.unlockLimiter.allow(app.rateLimitKey(r)); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		app.apiError(w, http.StatusTooManyRequests, "too many password attempts, try again later")
+		return false
+	}
+	err := app.snippets.Unlock(snippet.ShortID, password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.apiError(w, http.StatusUnauthorized, "incorrect snippet password")
+		} else if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return false
+	}
+	return true
+}
+
+// POST /api/v1/snippets creates a snippet owned by the logged in user, taking
+// the same fields as the create form
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	//same defaults as the create form
+	form := snippetCreateForm{
+		Expires:    "1w",
+		Visibility: models.VisibilityPublic,
+	}
+	err := app.readJSON(w, r, &form)
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	expires := form.validate()
+	if !form.Valid() {
+		app.apiValidationError(w, form.FieldErrors)
+		return
+	}
+
+	snippet := &models.Snippet{
+		Title:            form.Title,
+		Content:          form.Content,
+		Expires:          expiresAt(expires),
+		UserID:           app.authenticatedUserID(r),
+		Visibility:       form.Visibility,
+		BurnAfterReading: form.BurnAfterReading,
+		Language:         form.Language,
+	}
+	shortID, err := app.snippets.Insert(snippet, form.Password)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	//fill in what the database would have, saves reading it back
+	now := time.Now().UTC().Truncate(time.Second)
+	snippet.ShortID = shortID
+	snippet.Created = now
+	snippet.Updated = now
+	created := newSnippetJSON(snippet)
+	created.PasswordProtected = form.Password != ""
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%s", shortID))
+	app.writeJSON(w, http.StatusCreated, map[string]any{"snippet": created})
+}
+
+// PATCH /api/v1/snippets/:id changes the title, content or expiry of a snippet.
+// Title and content changes are saved as a new revision, like the edit form
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var patch snippetPatchJSON
+	err := app.readJSON(w, r, &patch)
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	form := snippetEditForm{Title: snippet.Title, Content: snippet.Content}
+	if patch.Title != nil {
+		form.Title = *patch.Title
+	}
+	if patch.Content != nil {
+		form.Content = *patch.Content
+	}
+	form.validate()
+
+	var expires time.Time
+	if patch.Expires != nil {
+		d, ok := parseExpiry(*patch.Expires)
+		form.CheckField(ok, "expires", "Field must be a duration like 10m, 1h, 1d, 1w or 1y (at most a year), or never")
+		expires = expiresAt(d)
+	}
+
+	if !form.Valid() {
+		app.apiValidationError(w, form.FieldErrors)
+		return
+	}
+
+	//the response is built from a copy rather than reading the snippet back
+	updated := *snippet
+	if patch.Title != nil || patch.Content != nil {
+		err = app.snippets.Update(snippet.ID, form.Title, form.Content)
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		updated.Title = form.Title
+		updated.Content = form.Content
+		updated.Updated = time.Now().UTC().Truncate(time.Second)
+	}
+
+	//an expiry earlier than the current one is ignored, same as the extend button
+	if patch.Expires != nil && extendsExpiry(snippet, expires) {
+		err = app.snippets.UpdateExpiry(snippet.ID, expires)
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		updated.Expires = expires
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippet": newSnippetJSON(&updated)})
+}
+
+// DELETE /api/v1/snippets/:id
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"net/http"
+	"snippetbox/internal/assert"
+	"snippetbox/internal/models/mocks"
+	"strings"
+	"testing"
+)
+
+func TestAPISnippetView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		password string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid ID",
+			urlPath:  "/api/v1/snippets/Xk3pQ9aZ1b",
+			wantCode: http.StatusOK,
+			wantBody: `"content":"An old silent pond..."`,
+		},
+		{
+			name:     "Private",
+			urlPath:  "/api/v1/snippets/Pv5tH8kL2q",
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"snippet not found"}`,
+		},
+		{
+			name:     "Invalid ID",
+			urlPath:  "/api/v1/snippets/foo",
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"snippet not found"}`,
+		},
+		{
+			name:     "Password protected",
+			urlPath:  "/api/v1/snippets/Lk2dF7gJ3s",
+			wantCode: http.StatusUnauthorized,
+			wantBody: "X-Snippet-Password",
+		},
+		{
+			name:     "Wrong password",
+			urlPath:  "/api/v1/snippets/Lk2dF7gJ3s",
+			password: "let me in",
+			wantCode: http.StatusUnauthorized,
+			wantBody: "incorrect snippet password",
+		},
+		{
+			name:     "Right password",
+			urlPath:  "/api/v1/snippets/Lk2dF7gJ3s",
+			password: "open sesame",
+			wantCode: http.StatusOK,
+			wantBody: `"password_protected":true`,
+		},
+		{
+			name:     "Burn after reading",
+			urlPath:  "/api/v1/snippets/Bn9rT4uY6w",
+			wantCode: http.StatusConflict,
+			wantBody: "POST to /api/v1/snippets/Bn9rT4uY6w/burn",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.password != "" {
+				header.Set("X-Snippet-Password", tt.password)
+			}
+			code, rsHeader, body := ts.sendJSON(t, http.MethodGet, tt.urlPath, "", header)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, rsHeader.Get("Content-Type"), "application/json")
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestAPISnippetPasswordRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	app.unlockLimiter = newRateLimiter(1, 1)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	//reads without a password don't use up the budget, guesses do
+	steps := []struct {
+		name     string
+		password string
+		wantCode int
+	}{
+		{name: "No password", wantCode: http.StatusUnauthorized},
+		{name: "Wrong password", password: "let me in", wantCode: http.StatusUnauthorized},
+		{name: "Too many guesses", password: "open sesame", wantCode: http.StatusTooManyRequests},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			header := http.Header{}
+			if step.password != "" {
+				header.Set("X-Snippet-Password", step.password)
+			}
+			code, rsHeader, _ := ts.sendJSON(t, http.MethodGet, "/api/v1/snippets/Lk2dF7gJ3s", "", header)
+			assert.Equal(t, code, step.wantCode)
+			if step.wantCode == http.StatusTooManyRequests {
+				assert.Equal(t, rsHeader.Get("Retry-After"), "60")
+			}
+		})
+	}
+}
+
+func TestAPISnippetBurn(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		body      string
+		wantCode  int
+		wantBody  string
+		wantCache string
+	}{
+		{
+			name:      "Burn after reading",
+			urlPath:   "/api/v1/snippets/Bn9rT4uY6w/burn",
+			body:      "{}",
+			wantCode:  http.StatusOK,
+			wantBody:  `"burn_after_reading":true`,
+			wantCache: "no-store",
+		},
+		{
+			name:     "Not burn after reading",
+			urlPath:  "/api/v1/snippets/Xk3pQ9aZ1b/burn",
+			body:     "{}",
+			wantCode: http.StatusOK,
+			wantBody: `"content":"An old silent pond..."`,
+		},
+		{
+			name:     "No JSON body",
+			urlPath:  "/api/v1/snippets/Bn9rT4uY6w/burn",
+			wantCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:     "Password protected",
+			urlPath:  "/api/v1/snippets/Lk2dF7gJ3s/burn",
+			body:     "{}",
+			wantCode: http.StatusUnauthorized,
+			wantBody: "X-Snippet-Password",
+		},
+		{
+			name:     "Invalid ID",
+			urlPath:  "/api/v1/snippets/foo/burn",
+			body:     "{}",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.sendJSON(t, http.MethodPost, tt.urlPath, tt.body, nil)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+			assert.Equal(t, header.Get("Cache-Control"), tt.wantCache)
+		})
+	}
+}
+
+func TestAPISnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, _, body := ts.sendJSON(t, http.MethodPost, "/api/v1/snippets", `{"title":"A title","content":"Some content"}`, nil)
+		assert.Equal(t, code, http.StatusUnauthorized)
+		assert.StringContains(t, body, `"error"`)
+	})
+
+	ts.login(t)
+
+	tests := []struct {
+		name         string
+		body         string
+		header       http.Header
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid submission",
+			body:         `{"title":"A title","content":"Some content","language":"go","expires":"1d"}`,
+			wantCode:     http.StatusCreated,
+			wantLocation: "/api/v1/snippets/Nw4sE6yH0j",
+			wantBody:     `"id":"Nw4sE6yH0j"`,
+		},
+		{
+			name:         "Defaults",
+			body:         `{"title":"A title","content":"Some content"}`,
+			wantCode:     http.StatusCreated,
+			wantLocation: "/api/v1/snippets/Nw4sE6yH0j",
+			wantBody:     `"visibility":"public"`,
+		},
+		{
+			name:     "Blank title",
+			body:     `{"title":"","content":"Some content"}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"fields":{"title":"This field cannot be blank, fill it in now"}`,
+		},
+		{
+			name:     "Invalid expiry",
+			body:     `{"title":"A title","content":"Some content","expires":"7"}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"expires":"Field must be a duration`,
+		},
+		{
+			name:     "Unknown field",
+			body:     `{"title":"A title","content":"Some content","colour":"red"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: `unknown field`,
+		},
+		{
+			name:     "Malformed JSON",
+			body:     `{"title":`,
+			wantCode: http.StatusBadRequest,
+			wantBody: `"error":"invalid JSON body`,
+		},
+		{
+			name:     "Form encoded",
+			body:     `title=A+title&content=Some+content`,
+			header:   http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			wantCode: http.StatusUnsupportedMediaType,
+			wantBody: `"error":"request body must be application/json"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.sendJSON(t, http.MethodPost, "/api/v1/snippets", tt.body, tt.header)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestAPISnippetUpdate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "New title",
+			urlPath:  "/api/v1/snippets/Xk3pQ9aZ1b",
+			body:     `{"title":"A new title"}`,
+			wantCode: http.StatusOK,
+			wantBody: `"title":"A new title","content":"An old silent pond..."`,
+		},
+		{
+			name:     "Never expire",
+			urlPath:  "/api/v1/snippets/Xk3pQ9aZ1b",
+			body:     `{"expires":"never"}`,
+			wantCode: http.StatusOK,
+			wantBody: `"expires":null`,
+		},
+		{
+			name:     "Blank content",
+			urlPath:  "/api/v1/snippets/Xk3pQ9aZ1b",
+			body:     `{"content":" "}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"content":"This field cannot be blank either, cmon dude"`,
+		},
+		{
+			name:     "Someone else's snippet",
+			urlPath:  "/api/v1/snippets/Rt7mW2cV8n",
+			body:     `{"title":"Mine now"}`,
+			wantCode: http.StatusForbidden,
+			wantBody: `"error"`,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/api/v1/snippets/Aa1Bb2Cc3D",
+			body:     `{"title":"A new title"}`,
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"snippet not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.sendJSON(t, http.MethodPatch, tt.urlPath, tt.body, nil)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestAPISnippetDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Own snippet",
+			urlPath:  "/api/v1/snippets/Xk3pQ9aZ1b",
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "Someone else's snippet",
+			urlPath:  "/api/v1/snippets/Rt7mW2cV8n",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Private snippet of someone else",
+			urlPath:  "/api/v1/snippets/Pv5tH8kL2q",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.sendJSON(t, http.MethodDelete, tt.urlPath, "", nil)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestAPITokenAuthentication(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		method        string
+		urlPath       string
+		body          string
+		authorization string
+		wantCode      int
+		wantBody      string
+	}{
+		{
+			name:          "Create with token",
+			method:        http.MethodPost,
+			urlPath:       "/api/v1/snippets",
+			body:          `{"title":"A title","content":"Some content"}`,
+			authorization: "Bearer " + mocks.MockToken,
+			wantCode:      http.StatusCreated,
+			wantBody:      `"id":"Nw4sE6yH0j"`,
+		},
+		{
+			name:          "Read with read-only token",
+			method:        http.MethodGet,
+			urlPath:       "/api/v1/snippets/Xk3pQ9aZ1b",
+			authorization: "Bearer " + mocks.MockReadOnlyToken,
+			wantCode:      http.StatusOK,
+			wantBody:      `"id":"Xk3pQ9aZ1b"`,
+		},
+		{
+			name:          "Missing write scope",
+			method:        http.MethodPost,
+			urlPath:       "/api/v1/snippets",
+			body:          `{"title":"A title","content":"Some content"}`,
+			authorization: "Bearer " + mocks.MockReadOnlyToken,
+			wantCode:      http.StatusForbidden,
+			wantBody:      `{"error":"API token needs the write scope"}`,
+		},
+		{
+			name:          "Missing delete scope",
+			method:        http.MethodDelete,
+			urlPath:       "/api/v1/snippets/Xk3pQ9aZ1b",
+			authorization: "Bearer " + mocks.MockReadOnlyToken,
+			wantCode:      http.StatusForbidden,
+			wantBody:      `{"error":"API token needs the delete scope"}`,
+		},
+		{
+			name:          "Unknown token",
+			method:        http.MethodGet,
+			urlPath:       "/api/v1/snippets",
+			authorization: "Bearer sb_nope",
+			wantCode:      http.StatusUnauthorized,
+			wantBody:      `{"error":"invalid or expired API token"}`,
+		},
+		{
+			name:          "Not a bearer token",
+			method:        http.MethodGet,
+			urlPath:       "/api/v1/snippets",
+			authorization: "Basic YWxpY2U6cGFzcw==",
+			wantCode:      http.StatusUnauthorized,
+			wantBody:      `{"error":"invalid or expired API token"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Authorization", tt.authorization)
+			code, _, body := ts.sendJSON(t, tt.method, tt.urlPath, tt.body, header)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestAPISnippetList(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		token    string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Latest",
+			urlPath:  "/api/v1/snippets",
+			wantCode: http.StatusOK,
+			wantBody: `"id":"Xk3pQ9aZ1b"`,
+		},
+		{
+			name:     "Own snippets",
+			urlPath:  "/api/v1/user/snippets",
+			token:    mocks.MockReadOnlyToken,
+			wantCode: http.StatusOK,
+			wantBody: `"id":"Xk3pQ9aZ1b"`,
+		},
+		{
+			name:     "Own snippets unauthenticated",
+			urlPath:  "/api/v1/user/snippets",
+			wantCode: http.StatusUnauthorized,
+			wantBody: `"error"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.token != "" {
+				header.Set("Authorization", "Bearer "+tt.token)
+			}
+			code, _, body := ts.sendJSON(t, http.MethodGet, tt.urlPath, "", header)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+			//lists never carry the content
+			assert.Equal(t, strings.Contains(body, `"content"`), false)
+		})
+	}
+}
@@ -5,3 +5,9 @@ type contextKey string
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
 
 // uniq key we can use to store and get auth status for request context
+
+// ID of the logged in user, set together with isAuthenticatedContextKey
+const authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+
+// the *models.Token an API request was authenticated with, not set for browser sessions
+const apiTokenContextKey = contextKey("apiToken")
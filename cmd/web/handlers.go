@@ -3,21 +3,88 @@ package main
 import (
 	"errors"
 	"fmt"
+	"math"
+	"mime"
 	"net/http"
+	textdiff "snippetbox/internal/diff"
 	"snippetbox/internal/models"
 	"snippetbox/internal/validator"
 	"strconv"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
 
+// bcrypt only looks at the first 72 bytes of a password, so longer ones are
+// turned away wherever a password is set rather than quietly cut short
+const (
+	maxPasswordBytes = 72
+	passwordTooLong  = "This field cannot be more than 72 bytes long"
+)
+
 // struct to represent form data for form fields
 // struct must be exported and capitalized in order to be read by html/template package
+// the json tags let POST /api/v1/snippets decode into the same struct
 type snippetCreateForm struct {
-	Title               string     `form:"title"`
-	Content             string     `form:"content"`
-	Expires             int        `form:"expires"`
-	validator.Validator `form:"-"` //goes to Validators.go, embedding means this inherits all fields of the type Validator
+	Title               string              `form:"title" json:"title"`
+	Content             string              `form:"content" json:"content"`
+	Expires             string              `form:"expires" json:"expires"` //see parseExpiry
+	Visibility          string              `form:"visibility" json:"visibility"`
+	BurnAfterReading    bool                `form:"burn" json:"burn_after_reading"`
+	Language            string              `form:"language" json:"language"` //chroma lexer name, empty to auto-detect
+	Password            string              `form:"password" json:"password"` //optional, leave blank for no password
+	validator.Validator `form:"-" json:"-"` //goes to Validators.go, embedding means this inherits all fields of the type Validator
+}
+
+// validate checks the fields of a new snippet, shared by the create form and the API.
+// It returns the parsed expiry, which is only meaningful if the form is valid
+func (form *snippetCreateForm) validate() time.Duration {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank, fill it in now")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot more have than 100 chars long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank either, cmon dude")
+	expires, ok := parseExpiry(form.Expires)
+	form.CheckField(ok, "expires", "Field must be a duration like 10m, 1h, 1d, 1w or 1y (at most a year), or never")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "Field must be public, unlisted or private")
+	form.CheckField(validator.PermittedValue(form.Language, languageNames()...), "language", "Please pick a language from the list")
+	form.CheckField(validator.MaxBytes(form.Password, maxPasswordBytes), "password", passwordTooLong)
+	return expires
+}
+
+// edit form only lets the owner change title and content, expiry stays as is
+type snippetEditForm struct {
+	Title               string `form:"title"`
+	Content             string `form:"content"`
+	validator.Validator `form:"-"`
+}
+
+// validate checks an edited title and content, shared by the edit form and the API
+func (form *snippetEditForm) validate() {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank, fill it in now")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot more have than 100 chars long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank either, cmon dude")
+}
+
+type snippetUnlockForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// form for creating an API token on the tokens page
+type tokenCreateForm struct {
+	Name                string   `form:"name"`
+	Scopes              []string `form:"scopes"`
+	Expires             string   `form:"expires"` //see parseExpiry
+	validator.Validator `form:"-"`
+}
+
+// HasScope is used by the template to keep the scope checkboxes ticked
+func (form tokenCreateForm) HasScope(scope string) bool {
+	for _, s := range form.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
 }
 
 type userSignupForm struct {
@@ -32,6 +99,22 @@ type userLoginForm struct {
 	validator.Validator `form:"-"`
 }
 
+// asks for a password reset link to be emailed out
+type userForgotForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+// sets a new password, the token comes from the URL rather than the form
+type userResetForm struct {
+	Token               string `form:"-"`
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// how long an emailed password reset link works for
+const passwordResetTTL = time.Hour
+
 // Our Handlers, it handels rendering stuff to user
 // *http.request param is a pointer to a struct which holds info like http method and URL
 
@@ -49,15 +132,82 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	app.render(w, http.StatusOK, "home.tmpl", data)
 }
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	params := httprouter.ParamsFromContext(r.Context())
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return
+	}
 
-	id, err := strconv.Atoi(params.ByName("id"))
-	if err != nil || id < 1 {
-		app.notFound(w)
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	//burn after reading snippets get a warning page first, the content is
+	//only sent once the reader confirms with a POST
+	if snippet.BurnAfterReading {
+		w.Header().Set("Cache-Control", "no-store")
+		app.render(w, http.StatusOK, "burn.tmpl", data)
 		return
 	}
+	// Use the new render helper.
 
-	snippet, err := app.snippets.Get(id)
+	app.render(w, http.StatusOK, "view.tmpl", data)
+}
+
+// checks the password for a protected snippet and remembers it in the session
+func (app *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.findSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetUnlockForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	if form.Valid() {
+		err = app.snippets.Unlock(snippet.ShortID, form.Password)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				form.AddNonFieldError("That password is incorrect")
+			} else if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+				return
+			} else {
+				app.serverError(w, err)
+				return
+			}
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "unlock.tmpl", data)
+		return
+	}
+
+	//reloading the page won't ask for the password again until the session ends
+	app.sessionManager.Put(r.Context(), "unlocked:"+snippet.ShortID, true)
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.ShortID), http.StatusSeeOther)
+}
+
+// reveals a burn after reading snippet, deleting it as it goes
+func (app *application) snippetBurnPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return
+	}
+	if !snippet.BurnAfterReading {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.ShortID), http.StatusSeeOther)
+		return
+	}
+
+	//Burn is atomic, if someone else got here first this is ErrNoRecord
+	snippet, err := app.snippets.Burn(snippet.ShortID)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
@@ -69,18 +219,124 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
-	// Use the new render helper.
-
+	//make sure the one and only copy doesn't end up in a cache somewhere
+	w.Header().Set("Cache-Control", "no-store")
 	app.render(w, http.StatusOK, "view.tmpl", data)
 }
 
+// serves just the content as plain text with no layout, handy for curl
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.rawSnippet(w, r)
+	if !ok {
+		return
+	}
+	app.serveSnippet(w, r, snippet)
+}
+
+// same as snippetRaw but the browser saves it as a file named after the title
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.rawSnippet(w, r)
+	if !ok {
+		return
+	}
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": snippetFilename(snippet)})
+	w.Header().Set("Content-Disposition", disposition)
+	app.serveSnippet(w, r, snippet)
+}
+
+// lists every saved version of a snippet
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return
+	}
+	//the history would let people read a burn after reading snippet without burning it
+	if snippet.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+	app.render(w, http.StatusOK, "history.tmpl", data)
+}
+
+// shows the line changes between the two revisions given in the from and to query params
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return
+	}
+	if snippet.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := strconv.Atoi(query.Get("from"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	to, err := strconv.Atoi(query.Get("to"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	fromRevision, err := app.snippets.GetRevision(snippet.ID, from)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	toRevision, err := app.snippets.GetRevision(snippet.ID, to)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	diff := &revisionDiff{
+		From: fromRevision,
+		To:   toRevision,
+	}
+	diff.Hunks, err = textdiff.Unified(diff.From.Content, diff.To.Content, 3)
+	if err != nil {
+		if !errors.Is(err, textdiff.ErrTooDifferent) {
+			app.serverError(w, err)
+			return
+		}
+		diff.TooDifferent = true
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Diff = diff
+	app.render(w, http.StatusOK, "diff.tmpl", data)
+}
+
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	//w.Write([]byte("Display the form for creating a new snippet..."))
 	data := app.newTemplateData(r)
 
 	//init new createsnippetform instance pass to template
 	data.Form = snippetCreateForm{
-		Expires: 7,
+		Expires:    "1w",
+		Visibility: models.VisibilityPublic,
 	}
 	app.render(w, http.StatusOK, "create.tmpl", data)
 }
@@ -97,12 +353,8 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	//init a map to hold any validation errors from taking in the form fields
-
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank, fill it in now")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot more have than 100 chars long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank either, cmon dude")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "Field must equal 1, 7 or 365")
+	//fills in the validation errors from taking in the form fields
+	expires := form.validate()
 	// error check, dump any in plain http response and return
 	if !form.Valid() {
 		data := app.newTemplateData(r)
@@ -111,9 +363,18 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Pass the data to the SnippetModel.Insert() method, receiving the
-	// ID of the new record back.
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	// Pass the data to the SnippetModel.Insert() method with the
+	// logged in user as owner, receiving the short ID of the new record back.
+	snippet := &models.Snippet{
+		Title:            form.Title,
+		Content:          form.Content,
+		Expires:          expiresAt(expires),
+		UserID:           app.authenticatedUserID(r),
+		Visibility:       form.Visibility,
+		BurnAfterReading: form.BurnAfterReading,
+		Language:         form.Language,
+	}
+	shortID, err := app.snippets.Insert(snippet, form.Password)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -121,7 +382,225 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	// use put method to add string value and add key to session data
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 	// Redirect the user to the relevant page for the snippet.
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", shortID), http.StatusSeeOther)
+}
+
+// findSnippet looks up the snippet named in the URL.
+// If there isn't one, the right error response is sent and ok is false so the caller can just return
+func (app *application) findSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, err := app.snippetFromRequest(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+	return snippet, true
+}
+
+// snippetFromRequest looks up the snippet named by the :id param, returning
+// ErrNoRecord for IDs that can't exist and for private snippets of other users
+func (app *application) snippetFromRequest(r *http.Request) (*models.Snippet, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	//snippets are looked up by their random short ID, the numeric one is internal only
+	id := params.ByName("id")
+	if !models.ValidShortID(id) {
+		return nil, models.ErrNoRecord
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	//private snippets don't exist as far as anyone but the owner can tell
+	if snippet.Visibility == models.VisibilityPrivate && !app.ownsSnippet(r, snippet) {
+		return nil, models.ErrNoRecord
+	}
+	return snippet, nil
+}
+
+// viewableSnippet is findSnippet plus the password check. If the snippet is
+// password protected and hasn't been unlocked in this session, the unlock form
+// is rendered instead and ok is false
+func (app *application) viewableSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.findSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !app.snippetUnlocked(r, snippet) {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = snippetUnlockForm{}
+		app.render(w, http.StatusOK, "unlock.tmpl", data)
+		return nil, false
+	}
+	return snippet, true
+}
+
+// rawSnippet is findSnippet for the raw and download endpoints. Burn after
+// reading and locked snippets are sent to the view page, which has the
+// confirmation and password forms, instead of being served straight away
+func (app *application) rawSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.findSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+	if snippet.BurnAfterReading || !app.snippetUnlocked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.ShortID), http.StatusSeeOther)
+		return nil, false
+	}
+	return snippet, true
+}
+
+// snippetUnlocked reports whether the snippet can be read without asking for its password,
+// either because it hasn't got one, the reader owns it or it was unlocked earlier in this session
+func (app *application) snippetUnlocked(r *http.Request, snippet *models.Snippet) bool {
+	if !snippet.HasPassword() {
+		return true
+	}
+	if app.ownsSnippet(r, snippet) {
+		return true
+	}
+	return app.sessionManager.GetBool(r.Context(), "unlocked:"+snippet.ShortID)
+}
+
+// ownedSnippet is findSnippet plus a check that the snippet belongs to the logged in user
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.findSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+	//only the user who created the snippet may change it
+	if !app.ownsSnippet(r, snippet) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+	return snippet, true
+}
+
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	//prefill form with the current values
+	data.Form = snippetEditForm{
+		Title:   snippet.Title,
+		Content: snippet.Content,
+	}
+	app.render(w, http.StatusOK, "edit.tmpl", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetEditForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate()
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	err = app.snippets.Update(snippet.ID, form.Title, form.Content)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.ShortID), http.StatusSeeOther)
+}
+
+// pushes back the expiry of a snippet, it is never brought forward
+func (app *application) snippetExtendPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	//the choices come from a select on the view page, so anything else is a bad request
+	d, ok := parseExpiry(r.PostForm.Get("expires"))
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	expires := expiresAt(d)
+
+	if !extendsExpiry(snippet, expires) {
+		app.sessionManager.Put(r.Context(), "flash", "Snippet already expires after that, nothing changed")
+	} else {
+		err = app.snippets.UpdateExpiry(snippet.ID, expires)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "flash", "Snippet expiry extended!")
+	}
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.ShortID), http.StatusSeeOther)
+}
+
+// extendsExpiry reports whether expires (zero meaning never) is later than
+// the current expiry of the snippet
+func extendsExpiry(snippet *models.Snippet, expires time.Time) bool {
+	if snippet.Expires.IsZero() {
+		return false
+	}
+	return expires.IsZero() || !expires.Before(snippet.Expires)
+}
+
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(snippet.ID)
+	if err != nil {
+		//could have been removed by another request since we looked it up
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully deleted!")
+	http.Redirect(w, r, "/user/snippets", http.StatusSeeOther)
+}
+
+// lists the snippets owned by the logged in user
+func (app *application) userSnippets(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.ListByUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+
+	app.render(w, http.StatusOK, "snippets.tmpl", data)
 }
 
 // Login Area funcs
@@ -148,6 +627,7 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
 	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
 	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	form.CheckField(validator.MaxBytes(form.Password, maxPasswordBytes), "password", passwordTooLong)
 
 	// if any errors, redisplay signup for wiht 422 statuscode
 	if !form.Valid() {
@@ -158,7 +638,7 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	}
 	// Try to create a new user record in the database. If the email already
 	// exists then add an error message to the form and re-display it.
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	id, err := app.users.Insert(form.Name, form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldError("email", "Email address is already in use")
@@ -172,8 +652,14 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 
 		return
 	}
+	// the account exists now whatever happens, a failed email can be resent from the verify page
+	user := &models.User{ID: id, Name: form.Name, Email: form.Email}
+	err = app.sendVerifyEmail(r, user)
+	if err != nil {
+		app.errorLog.Printf("sending verification email to user %d: %v", id, err)
+	}
 	//otherwise add flash message to confirm signupworked
-	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in")
+	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Check your email for a link to verify your address, then log in")
 	//redirect page to login
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 
@@ -203,10 +689,37 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
 		return
 	}
+	// too many failures turn people away before the password is even checked,
+	// or a guesser would still find out when they got it right
+	ip := app.clientIP(r)
+	wait, locked, err := app.loginWait(form.Email, ip)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if wait > 0 {
+		err = app.loginAttempts.Insert(form.Email, ip, models.LoginBlocked)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		form.AddNonFieldError(lockoutMessage(wait, locked))
+		data := app.newTemplateData(r)
+		data.Form = form
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		app.render(w, http.StatusTooManyRequests, "login.tmpl", data)
+		return
+	}
+
 	// check if cred are valid, if not add nonfielderr and goback2login
 	id, err := app.users.Authenticate(form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
+			err = app.loginAttempts.Insert(form.Email, ip, models.LoginFailure)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
 			form.AddNonFieldError("Email or password is incorrect")
 			data := app.newTemplateData(r)
 			data.Form = form
@@ -217,6 +730,44 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// with two-factor logins on the password is only half of it, the session
+	// just remembers who got it right until they give a code too. Nothing is
+	// recorded yet as a success would reset the count of failures, letting
+	// someone with the password guess codes for ever
+	tf, err := app.twoFactor.Get(id)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+	if tf != nil && tf.Enabled {
+		err = app.sessionManager.RenewToken(r.Context())
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "twoFactorUserID", id)
+		app.sessionManager.Put(r.Context(), "twoFactorExpires", time.Now().Add(twoFactorLoginTTL).Unix())
+		http.Redirect(w, r, "/user/login/2fa", http.StatusSeeOther)
+		return
+	}
+	app.logIn(w, r, form.Email, id)
+}
+
+// logIn records a successful login and puts the user in the session, once
+// they have given their password and a two-factor code if they need one
+func (app *application) logIn(w http.ResponseWriter, r *http.Request, email string, id int) {
+	ip := app.clientIP(r)
+	err := app.loginAttempts.Insert(email, ip, models.LoginSuccess)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	sessionID, err := app.userSessions.Insert(id, ip, r.UserAgent(), time.Now().Add(app.sessionManager.Lifetime))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	// use renewtocken on current session to change sessID,
 	// always generate a new session IF when auth state or priv changes for user
 	err = app.sessionManager.RenewToken(r.Context())
@@ -224,12 +775,15 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, err)
 		return
 	}
+	app.sessionManager.Remove(r.Context(), "twoFactorUserID")
+	app.sessionManager.Remove(r.Context(), "twoFactorExpires")
 	// add id of current user to session so they are not logged in!
 	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	app.sessionManager.Put(r.Context(), "sessionID", sessionID)
 	// redirect user to create a snippet
 	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
-
 }
+
 func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	// logout the user
 	//userewnewtoken method on curr session id
@@ -239,8 +793,16 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// it's gone from the sessions page too
+	err = app.userSessions.Delete(app.sessionManager.GetInt(r.Context(), "sessionID"), app.authenticatedUserID(r))
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
 	// remove auth userID from session data
 	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.sessionManager.Remove(r.Context(), "sessionID")
 	// add flash message to session to tell user it worked
 	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
 	// redirect to home
@@ -248,6 +810,210 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func (app *application) userForgot(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userForgotForm{}
+	app.render(w, http.StatusOK, "forgot.tmpl", data)
+}
+
+// userForgotPost emails a reset link if there is an account for the email.
+// The reply is the same either way, so it can't be used to find out who has an
+// account. That goes for how long it takes too, so the email is sent after
+// replying and a failure to send is only logged
+func (app *application) userForgotPost(w http.ResponseWriter, r *http.Request) {
+	var form userForgotForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "forgot.tmpl", data)
+		return
+	}
+
+	token, err := app.passwordResets.Insert(form.Email, passwordResetTTL)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+	if err == nil {
+		link := app.absoluteURL(r, "/user/password/reset/"+token)
+		body := fmt.Sprintf(passwordResetEmail, link)
+		app.background(func() {
+			err := app.mailer.Send(form.Email, "Reset your Snippetbox password", body)
+			if err != nil {
+				app.errorLog.Printf("sending password reset email: %v", err)
+			}
+		})
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "If there is an account for that email, a link to reset its password is on its way")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+const passwordResetEmail = `Hi,
+
+Someone, hopefully you, asked to reset the password of your Snippetbox
+account. Follow this link to pick a new one, it works once within the next hour:
+
+%s
+
+If it wasn't you, ignore this email and your password stays as it is.
+`
+
+func (app *application) userReset(w http.ResponseWriter, r *http.Request) {
+	token := httprouter.ParamsFromContext(r.Context()).ByName("token")
+	err := app.passwordResets.Check(token)
+	if err != nil {
+		app.resetLinkInvalid(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = userResetForm{Token: token}
+	app.render(w, http.StatusOK, "reset.tmpl", data)
+}
+
+func (app *application) userResetPost(w http.ResponseWriter, r *http.Request) {
+	form := userResetForm{Token: httprouter.ParamsFromContext(r.Context()).ByName("token")}
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	form.CheckField(validator.MaxBytes(form.Password, maxPasswordBytes), "password", passwordTooLong)
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "reset.tmpl", data)
+		return
+	}
+
+	id, err := app.passwordResets.Reset(form.Token, form.Password)
+	if err != nil {
+		app.resetLinkInvalid(w, r, err)
+		return
+	}
+	//whoever had the old password may still be logged in with it, or have
+	//made API tokens with it
+	err = app.userSessions.DeleteByUser(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.tokens.DeleteByUser(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been reset and your API tokens revoked, log in with the new password")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// resetLinkInvalid sends someone with a used or expired reset link back to ask for another
+func (app *application) resetLinkInvalid(w http.ResponseWriter, r *http.Request, err error) {
+	if !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "That reset link is invalid or has expired, ask for a new one")
+	http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
+}
+
+// lists the API tokens of the logged in user, with a form to make a new one
+func (app *application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = tokenCreateForm{
+		Scopes:  []string{models.ScopeRead},
+		Expires: "30d",
+	}
+	app.renderTokens(w, r, http.StatusOK, data)
+}
+
+func (app *application) accountTokensPost(w http.ResponseWriter, r *http.Request) {
+	var form tokenCreateForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 chars long")
+	form.CheckField(len(form.Scopes) > 0, "scopes", "Pick at least one scope")
+	for _, scope := range form.Scopes {
+		form.CheckField(validator.PermittedValue(scope, models.Scopes...), "scopes", "Scopes must be read, write or delete")
+	}
+	expires, ok := parseExpiry(form.Expires)
+	form.CheckField(ok, "expires", "Field must be a duration like 30d or 1y (at most a year), or never")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.renderTokens(w, r, http.StatusUnprocessableEntity, data)
+		return
+	}
+
+	token, err := app.tokens.Insert(app.authenticatedUserID(r), form.Name, form.Scopes, expiresAt(expires))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	//the token is rendered straight into this response rather than going
+	//through the session and a redirect, so the plaintext is never stored anywhere
+	data := app.newTemplateData(r)
+	data.NewToken = token
+	data.Form = tokenCreateForm{
+		Scopes:  []string{models.ScopeRead},
+		Expires: "30d",
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	app.renderTokens(w, r, http.StatusOK, data)
+}
+
+func (app *application) accountTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	//Delete only matches tokens of this user, anyone else's is a 404
+	err = app.tokens.Delete(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "API token revoked, it can't be used any more")
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+// renderTokens fills in the token list and renders the tokens page
+func (app *application) renderTokens(w http.ResponseWriter, r *http.Request, status int, data *templateData) {
+	tokens, err := app.tokens.ListByUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Tokens = tokens
+	app.render(w, status, "tokens.tmpl", data)
+}
+
 func ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
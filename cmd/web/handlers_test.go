@@ -2,8 +2,15 @@ package main
 
 //test our http runnin's
 import (
+	"bytes"
+	"errors"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"snippetbox/internal/assert"
+	"snippetbox/internal/mailer"
+	"snippetbox/internal/models/mocks"
+	"strings"
 	"testing"
 )
 
@@ -44,13 +51,28 @@ func TestSnippetView(t *testing.T) {
 	}{
 		{
 			name:     "Valid ID",
-			urlPath:  "/snippet/view/1",
+			urlPath:  "/snippet/view/Xk3pQ9aZ1b",
 			wantCode: http.StatusOK,
 			wantBody: "An old silent pond...",
 		},
 		{
 			name:     "Non-existent ID",
-			urlPath:  "/snippet/view/2",
+			urlPath:  "/snippet/view/Aa1Bb2Cc3D",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Someone else's private snippet",
+			urlPath:  "/snippet/view/Pv5tH8kL2q",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Internal numeric ID",
+			urlPath:  "/snippet/view/1",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Too long ID",
+			urlPath:  "/snippet/view/Xk3pQ9aZ1bc",
 			wantCode: http.StatusNotFound,
 		},
 		{
@@ -87,6 +109,178 @@ func TestSnippetView(t *testing.T) {
 	}
 }
 
+func TestSnippetBurn(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Viewing a burn after reading snippet only shows the warning page.
+	code, _, body := ts.get(t, "/snippet/view/Bn9rT4uY6w")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "This snippet will be shown only once")
+	if strings.Contains(body, "transferred to another candle") {
+		t.Error("burn after reading content was sent before confirming")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:     "Burn snippet",
+			urlPath:  "/snippet/view/Bn9rT4uY6w",
+			wantCode: http.StatusOK,
+			wantBody: "The light of a candle is transferred to another candle...",
+		},
+		{
+			name:         "Ordinary snippet",
+			urlPath:      "/snippet/view/Xk3pQ9aZ1b",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Xk3pQ9aZ1b",
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/view/Aa1Bb2Cc3D",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	// The history would give the content away too.
+	code, _, _ = ts.get(t, "/snippet/view/Bn9rT4uY6w/history")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetUnlock(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Without the password only the unlock form is shown.
+	code, _, body := ts.get(t, "/snippet/view/Lk2dF7gJ3s")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "This snippet is password protected.")
+	if strings.Contains(body, "within every dewdrop") {
+		t.Error("protected content was sent before unlocking")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		password string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Blank password",
+			password: "",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Wrong password",
+			password: "open barley",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "That password is incorrect",
+		},
+		{
+			name:     "Right password",
+			password: "open sesame",
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/unlock/Lk2dF7gJ3s", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	// The unlock is remembered in the session, so reloading shows the content.
+	code, _, body = ts.get(t, "/snippet/view/Lk2dF7gJ3s")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "A world of dew, and within every dewdrop...")
+}
+
+func TestSnippetUnlockRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	app.unlockLimiter = newRateLimiter(1, 1)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.csrfToken(t, "/snippet/view/Lk2dF7gJ3s")
+	form := url.Values{}
+	form.Add("password", "open barley")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/snippet/unlock/Lk2dF7gJ3s", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	// The right password doesn't help once the guesses have run out.
+	form.Set("password", "open sesame")
+	code, header, _ := ts.postForm(t, "/snippet/unlock/Lk2dF7gJ3s", form)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, header.Get("Retry-After"), "60")
+}
+
+func TestUserSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.get(t, "/user/snippets")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	// Alice only sees her own snippet.
+	ts.login(t)
+	code, _, body := ts.get(t, "/user/snippets")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<a href='/snippet/view/Xk3pQ9aZ1b'>An old silent pond</a>")
+	if strings.Contains(body, "Pv5tH8kL2q") || strings.Contains(body, "Lk2dF7gJ3s") {
+		t.Error("snippets that aren't Alice's were listed")
+	}
+
+	// Carol hasn't made any, so she doesn't see Alice's.
+	jar, err := cookiejar.New(nil)
+	assert.NilError(t, err)
+	ts.Client().Jar = jar
+	ts.loginAs(t, "carol@example.com")
+	code, _, body = ts.get(t, "/user/snippets")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "You haven't created any snippets yet.")
+	if strings.Contains(body, "Xk3pQ9aZ1b") {
+		t.Error("another user's snippet was listed")
+	}
+}
+
 func TestUserSignup(t *testing.T) {
 	// Create the application struct containing our mocked dependencies and set
 	// up the test server for running an end-to-end test.
@@ -104,3 +298,873 @@ func TestUserSignup(t *testing.T) {
 	// the provided message to the test output.
 	t.Logf("CSRF token is: %q", csrfToken)
 }
+
+func TestUserSignupPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.csrfToken(t, "/user/signup")
+
+	tests := []struct {
+		name     string
+		password string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid",
+			password: "pa$$word",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Short password",
+			password: "pa$$",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be at least 8 characters long",
+		},
+		{
+			// More than bcrypt can hash, which would otherwise be a 500.
+			name:     "Long password",
+			password: strings.Repeat("é", 37),
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be more than 72 bytes long",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", "Erin")
+			form.Add("email", "erin@example.com")
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/signup", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestSnippetEdit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Anonymous users are sent to the login page.
+	code, header, _ := ts.get(t, "/snippet/edit/Xk3pQ9aZ1b")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	ts.login(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Owned snippet",
+			urlPath:  "/snippet/edit/Xk3pQ9aZ1b",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:     "Someone else's snippet",
+			urlPath:  "/snippet/edit/Rt7mW2cV8n",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/edit/Aa1Bb2Cc3D",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String ID",
+			urlPath:  "/snippet/edit/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetEditPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	csrfToken := ts.csrfToken(t, "/snippet/edit/Xk3pQ9aZ1b")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		title    string
+		content  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid submission",
+			urlPath:  "/snippet/edit/Xk3pQ9aZ1b",
+			title:    "A new title",
+			content:  "Some new content",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Blank content",
+			urlPath:  "/snippet/edit/Xk3pQ9aZ1b",
+			title:    "A new title",
+			content:  "",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank either",
+		},
+		{
+			name:     "Someone else's snippet",
+			urlPath:  "/snippet/edit/Rt7mW2cV8n",
+			title:    "A new title",
+			content:  "Some new content",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetDeletePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	csrfToken := ts.csrfToken(t, "/snippet/view/Xk3pQ9aZ1b")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Owned snippet",
+			urlPath:  "/snippet/delete/Xk3pQ9aZ1b",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Someone else's snippet",
+			urlPath:  "/snippet/delete/Rt7mW2cV8n",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/delete/Aa1Bb2Cc3D",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestSnippetDiff(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid revisions",
+			urlPath:  "/snippet/view/Xk3pQ9aZ1b/diff?from=1&to=2",
+			wantCode: http.StatusOK,
+			wantBody: "<span class='diff-insert'>&#43;An old silent pond...</span>", //html/template escapes the +
+		},
+		{
+			name:     "Non-existent revision",
+			urlPath:  "/snippet/view/Xk3pQ9aZ1b/diff?from=1&to=3",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Missing revision",
+			urlPath:  "/snippet/view/Xk3pQ9aZ1b/diff?from=1",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Non-existent snippet",
+			urlPath:  "/snippet/view/Aa1Bb2Cc3D/diff?from=1&to=2",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetCreatePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	csrfToken := ts.csrfToken(t, "/snippet/create")
+
+	tests := []struct {
+		name         string
+		title        string
+		content      string
+		expires      string
+		visibility   string
+		language     string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid submission",
+			title:        "A title",
+			content:      "Some content",
+			expires:      "1w",
+			visibility:   "unlisted",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Nw4sE6yH0j",
+		},
+		{
+			name:       "Blank title",
+			title:      "",
+			content:    "Some content",
+			expires:    "1w",
+			visibility: "public",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This field cannot be blank, fill it in now",
+		},
+		{
+			name:         "Never expires",
+			title:        "A title",
+			content:      "Some content",
+			expires:      "never",
+			visibility:   "public",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Nw4sE6yH0j",
+		},
+		{
+			name:       "Invalid expiry",
+			title:      "A title",
+			content:    "Some content",
+			expires:    "7",
+			visibility: "public",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "Field must be a duration like 10m, 1h, 1d, 1w or 1y",
+		},
+		{
+			name:       "Invalid visibility",
+			title:      "A title",
+			content:    "Some content",
+			expires:    "1w",
+			visibility: "secret",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "Field must be public, unlisted or private",
+		},
+		{
+			name:         "With language",
+			title:        "A title",
+			content:      "package main",
+			expires:      "1w",
+			visibility:   "public",
+			language:     "go",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Nw4sE6yH0j",
+		},
+		{
+			name:       "Unknown language",
+			title:      "A title",
+			content:    "Some content",
+			expires:    "1w",
+			visibility: "public",
+			language:   "brainfuck",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "Please pick a language from the list",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("expires", tt.expires)
+			form.Add("visibility", tt.visibility)
+			form.Add("language", tt.language)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantBody        string
+		wantLocation    string
+		wantDisposition string
+	}{
+		{
+			name:     "Raw",
+			urlPath:  "/snippet/raw/Xk3pQ9aZ1b",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:            "Download",
+			urlPath:         "/snippet/download/Xk3pQ9aZ1b",
+			wantCode:        http.StatusOK,
+			wantBody:        "An old silent pond...",
+			wantDisposition: `attachment; filename=an-old-silent-pond.txt`,
+		},
+		{
+			name:     "Private",
+			urlPath:  "/snippet/raw/Pv5tH8kL2q",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Burn after reading",
+			urlPath:      "/snippet/raw/Bn9rT4uY6w",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Bn9rT4uY6w",
+		},
+		{
+			name:         "Password protected",
+			urlPath:      "/snippet/download/Lk2dF7gJ3s",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Lk2dF7gJ3s",
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/raw/Aa1Bb2Cc3D",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			assert.Equal(t, header.Get("Content-Disposition"), tt.wantDisposition)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, body, tt.wantBody)
+				assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+			}
+		})
+	}
+}
+
+// repeat fetches with the validators from the first response should get a 304 and no body
+func TestSnippetRawConditional(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, header, _ := ts.get(t, "/snippet/raw/Xk3pQ9aZ1b")
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		wantCode int
+	}{
+		{
+			name:     "Matching ETag",
+			header:   "If-None-Match",
+			value:    header.Get("ETag"),
+			wantCode: http.StatusNotModified,
+		},
+		{
+			name:     "Stale ETag",
+			header:   "If-None-Match",
+			value:    `"0123456789abcdef"`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Not modified since",
+			header:   "If-Modified-Since",
+			value:    header.Get("Last-Modified"),
+			wantCode: http.StatusNotModified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippet/raw/Xk3pQ9aZ1b", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set(tt.header, tt.value)
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+		})
+	}
+}
+
+// hostile input is echoed back when the form fails validation, it should come
+// back escaped rather than as markup
+func TestSnippetCreatePostEscaping(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	csrfToken := ts.csrfToken(t, "/snippet/create")
+
+	tests := []struct {
+		name        string
+		title       string
+		content     string
+		wantBody    string
+		notWantBody string
+	}{
+		{
+			name:        "Script in title",
+			title:       "<script>alert('title')</script>",
+			content:     "Some content",
+			wantBody:    "&lt;script&gt;alert(&#39;title&#39;)&lt;/script&gt;",
+			notWantBody: "<script>alert('title')",
+		},
+		{
+			name:        "Breaking out of the attribute",
+			title:       "' autofocus onfocus='alert(1)",
+			content:     "Some content",
+			wantBody:    "&#39; autofocus onfocus=&#39;alert(1)",
+			notWantBody: "' autofocus onfocus='alert(1)",
+		},
+		{
+			name:        "Closing the textarea",
+			title:       "A title",
+			content:     "</textarea><img src=x onerror=alert(1)>",
+			wantBody:    "&lt;/textarea&gt;&lt;img src=x onerror=alert(1)&gt;",
+			notWantBody: "<img src=x onerror=alert(1)>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("expires", "7") //invalid so the form is rendered again
+			form.Add("visibility", "public")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, http.StatusUnprocessableEntity)
+			assert.StringContains(t, body, tt.wantBody)
+			assert.Equal(t, strings.Contains(body, tt.notWantBody), false)
+		})
+	}
+}
+
+func TestAccountTokensPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	csrfToken := ts.csrfToken(t, "/account/tokens")
+
+	tests := []struct {
+		name     string
+		tokName  string
+		scopes   []string
+		expires  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid submission",
+			tokName:  "deploy script",
+			scopes:   []string{"read", "write"},
+			expires:  "30d",
+			wantCode: http.StatusOK,
+			wantBody: "<code>sb_Nw4sE6yH0jNw4sE6yH0jNw4sE6yH0jNw4sE6yH0j</code>",
+		},
+		{
+			name:     "No scopes",
+			tokName:  "deploy script",
+			expires:  "30d",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Pick at least one scope",
+		},
+		{
+			name:     "Unknown scope",
+			tokName:  "deploy script",
+			scopes:   []string{"admin"},
+			expires:  "30d",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Scopes must be read, write or delete",
+		},
+		{
+			name:     "Blank name",
+			scopes:   []string{"read"},
+			expires:  "never",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.tokName)
+			for _, scope := range tt.scopes {
+				form.Add("scopes", scope)
+			}
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/account/tokens", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestAccountTokenRevokePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	csrfToken := ts.csrfToken(t, "/account/tokens")
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Own token",
+			urlPath:      "/account/tokens/revoke/1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/tokens",
+		},
+		{
+			name:     "Someone else's token",
+			urlPath:  "/account/tokens/revoke/7",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			urlPath:  "/account/tokens/revoke/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestUserLoginPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.csrfToken(t, "/user/login")
+
+	tests := []struct {
+		name           string
+		email          string
+		password       string
+		wantCode       int
+		wantRetryAfter string
+		wantBody       string
+	}{
+		{
+			name:     "Wrong password",
+			email:    "alice@example.com",
+			password: "wrong",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Email or password is incorrect",
+		},
+		{
+			name:           "Backed off",
+			email:          "slow@example.com",
+			password:       "pa$$word",
+			wantCode:       http.StatusTooManyRequests,
+			wantRetryAfter: "20",
+			wantBody:       "Too many failed logins, wait 20 seconds before trying again",
+		},
+		{
+			name:           "Locked out",
+			email:          "locked@example.com",
+			password:       "pa$$word",
+			wantCode:       http.StatusTooManyRequests,
+			wantRetryAfter: "900",
+			wantBody:       "logging in is locked for now. Try again in 15 minutes",
+		},
+		{
+			name:     "Valid",
+			email:    "alice@example.com",
+			password: "pa$$word",
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, "/user/login", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Retry-After"), tt.wantRetryAfter)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestUserForgotPost(t *testing.T) {
+	app := newTestApplication(t)
+	var sent bytes.Buffer
+	app.mailer = &mailer.Log{Out: &sent}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.csrfToken(t, "/user/password/forgot")
+
+	tests := []struct {
+		name         string
+		email        string
+		wantCode     int
+		wantLocation string
+		wantMail     string
+	}{
+		{
+			name:         "Known email",
+			email:        "alice@example.com",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/login",
+			wantMail:     "/user/password/reset/" + mocks.MockResetToken,
+		},
+		{
+			//same reply, so nobody can find out who has an account
+			name:         "Unknown email",
+			email:        "nobody@example.com",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/login",
+		},
+		{
+			name:     "Invalid email",
+			email:    "alice@",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent.Reset()
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, _ := ts.postForm(t, "/user/password/forgot", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			//the email is sent after the reply
+			app.wg.Wait()
+			if tt.wantMail == "" {
+				assert.Equal(t, sent.String(), "")
+			} else {
+				assert.StringContains(t, sent.String(), "To: "+tt.email)
+				assert.StringContains(t, sent.String(), tt.wantMail)
+			}
+		})
+	}
+}
+
+// failingMailer is a mailer whose SMTP server is down
+type failingMailer struct{}
+
+func (failingMailer) Send(to, subject, body string) error {
+	return errors.New("dial tcp: connection refused")
+}
+
+func TestUserForgotPostMailerDown(t *testing.T) {
+	app := newTestApplication(t)
+	app.mailer = failingMailer{}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.csrfToken(t, "/user/password/forgot")
+
+	// Failing to send mustn't give away which emails have an account.
+	for _, email := range []string{"alice@example.com", "nobody@example.com"} {
+		t.Run(email, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", email)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, _ := ts.postForm(t, "/user/password/forgot", form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, header.Get("Location"), "/user/login")
+			app.wg.Wait()
+		})
+	}
+}
+
+func TestUserResetPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/user/password/reset/"+mocks.MockResetToken)
+	assert.Equal(t, code, http.StatusOK)
+	csrfToken := extractCSRFToken(t, body)
+
+	code, header, _ := ts.get(t, "/user/password/reset/wrong")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/password/forgot")
+
+	tests := []struct {
+		name         string
+		token        string
+		password     string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:     "Short password",
+			token:    mocks.MockResetToken,
+			password: "pa$$",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be at least 8 characters long",
+		},
+		{
+			name:         "Used or expired token",
+			token:        "wrong",
+			password:     "n3w pa$$word",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/password/forgot",
+		},
+		{
+			name:         "Valid",
+			token:        mocks.MockResetToken,
+			password:     "n3w pa$$word",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/login",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, "/user/password/reset/"+tt.token, form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestUserResetPostSignsOut(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Whoever knew the old password is logged in when it is reset.
+	ts.login(t)
+	code, _, _ := ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusOK)
+
+	form := url.Values{}
+	form.Add("password", "n3w pa$$word")
+	form.Add("csrf_token", ts.csrfToken(t, "/user/password/reset/"+mocks.MockResetToken))
+	code, _, _ = ts.postForm(t, "/user/password/reset/"+mocks.MockResetToken, form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, header, _ := ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	// Their API tokens stop working too.
+	apiHeader := http.Header{}
+	apiHeader.Set("Authorization", "Bearer "+mocks.MockToken)
+	code, _, _ = ts.sendJSON(t, http.MethodGet, "/api/v1/user/snippets", "", apiHeader)
+	assert.Equal(t, code, http.StatusUnauthorized)
+}
@@ -2,10 +2,17 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
 	"runtime/debug"
+	"snippetbox/internal/models"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/form/v4"
@@ -21,6 +28,22 @@ func (app *application) serverError(w http.ResponseWriter, err error) {
 
 }
 
+// background runs fn in its own goroutine, for work a request shouldn't wait
+// on or that lasts until shutdown. main waits for it before exiting, and a
+// panic is logged rather than taking the server down
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.errorLog.Output(2, fmt.Sprintf("%v\n%s", err, debug.Stack()))
+			}
+		}()
+		fn()
+	}()
+}
+
 func (app *application) clientError(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
@@ -68,8 +91,9 @@ func (app *application) newTemplateData(r *http.Request) *templateData {
 		Flash: app.sessionManager.PopString(r.Context(), "flash"),
 
 		//auth status to template data
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken:       nosurf.Token(r), //added for sec
+		IsAuthenticated:     app.isAuthenticated(r),
+		AuthenticatedUserID: app.authenticatedUserID(r),
+		CSRFToken:           nosurf.Token(r), //added for sec
 	}
 }
 
@@ -105,3 +129,223 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// returns the ID of the logged in user, or 0 if the request is not authenticated.
+// Set by authenticate for sessions and authenticateToken for API tokens
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+	return id
+}
+
+// emailVerified reports whether the logged in user has verified their email address
+func (app *application) emailVerified(r *http.Request) (bool, error) {
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerified, nil
+}
+
+// bearerToken returns the API token sent in the Authorization header, nil if
+// there isn't one. A header that isn't a valid Bearer token is ErrInvalidCredentials
+func (app *application) bearerToken(r *http.Request) (*models.Token, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, nil
+	}
+	plaintext, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return nil, models.ErrInvalidCredentials
+	}
+	return app.tokens.Authenticate(plaintext)
+}
+
+// absoluteURL turns a path into a full URL, starting with -base-url if it was
+// set and the Host the request was sent to otherwise
+func (app *application) absoluteURL(r *http.Request, path string) string {
+	if app.baseURL != "" {
+		return strings.TrimRight(app.baseURL, "/") + path
+	}
+	scheme := "https"
+	if r.TLS == nil {
+		scheme = "http"
+	}
+	return scheme + "://" + r.Host + path
+}
+
+// clientIP is the address the request came from, without the port. When that
+// is one of -trusted-proxies it is the last address in X-Forwarded-For that
+// isn't a trusted proxy, as anything before it could have been made up by the client
+func (app *application) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !app.trustedProxy(ip) {
+		return ip
+	}
+
+	//proxies append the address they got the request from, so walk back from the end
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		ip = hop
+		if !app.trustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// trustedProxy reports whether ip is in one of the -trusted-proxies ranges
+func (app *application) trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range app.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses a comma separated list of IPs and CIDR ranges
+func parseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if strings.Contains(field, "/") {
+			prefix, err := netip.ParsePrefix(field)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// rateLimitKey is who a request counts against: the logged in user, so
+// everyone behind one NAT isn't sharing a budget, or else the client IP
+func (app *application) rateLimitKey(r *http.Request) string {
+	if id := app.authenticatedUserID(r); id != 0 {
+		return "user:" + strconv.Itoa(id)
+	}
+	return app.clientIP(r)
+}
+
+// ownsSnippet reports whether the logged in user created the snippet. Nobody
+// owns anonymous snippets, or ones whose account has been removed
+func (app *application) ownsSnippet(r *http.Request, snippet *models.Snippet) bool {
+	id := app.authenticatedUserID(r)
+	return id != 0 && snippet.UserID == id
+}
+
+// longest a snippet can be kept before it expires, other than never
+const maxExpiry = 365 * 24 * time.Hour
+
+// parseExpiry turns an expiry like 10m, 1h, 1d, 1w or 1y into a duration. "never"
+// gives 0. Anything unparseable, under a minute or over a year gives ok = false
+func parseExpiry(s string) (d time.Duration, ok bool) {
+	if s == "never" {
+		return 0, true
+	}
+	if len(s) < 2 {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 1 {
+		return 0, false
+	}
+
+	var unit time.Duration
+	switch s[len(s)-1] {
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	case 'y':
+		unit = 365 * 24 * time.Hour
+	default:
+		return 0, false
+	}
+
+	//checked before multiplying so huge numbers can't overflow
+	if n > int(maxExpiry/unit) {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// expiresAt turns an expiry duration from parseExpiry into the time the snippet
+// expires, or the zero time if it never does
+func expiresAt(d time.Duration) time.Time {
+	if d == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+// serveSnippet writes the content of a snippet as plain text. The ETag and
+// Last-Modified headers let http.ServeContent answer repeat fetches with a 304
+func (app *application) serveSnippet(w http.ResponseWriter, r *http.Request, snippet *models.Snippet) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("ETag", snippetETag(snippet))
+	//private and password protected snippets mustn't end up in shared caches
+	w.Header().Set("Cache-Control", "private, no-cache")
+	http.ServeContent(w, r, "", snippet.Updated, strings.NewReader(snippet.Content))
+}
+
+// snippetETag hashes everything that ends up in a raw or download response,
+// the title and language are in there because they make up the filename
+func snippetETag(snippet *models.Snippet) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", snippet.Title, snippet.Language, snippet.Content)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// snippetFilename turns the title into a safe filename, "My Script!" in bash
+// becomes my-script.sh. Titles with nothing usable fall back to the short ID
+func snippetFilename(snippet *models.Snippet) string {
+	var sb strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(snippet.Title) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '_' || c == '.' {
+			sb.WriteRune(c)
+			dash = false
+		} else if !dash {
+			sb.WriteByte('-')
+			dash = true
+		}
+	}
+
+	name := strings.Trim(sb.String(), "-.")
+	if name == "" {
+		name = snippet.ShortID
+	}
+	return name + "." + languageExt(snippet.Language)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"snippetbox/internal/assert"
+	"snippetbox/internal/models"
+	"testing"
+	"time"
+)
+
+func TestParseExpiry(t *testing.T) {
+	tests := []struct {
+		name   string
+		expiry string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "Minutes", expiry: "10m", want: 10 * time.Minute, wantOK: true},
+		{name: "Hours", expiry: "1h", want: time.Hour, wantOK: true},
+		{name: "Days", expiry: "3d", want: 72 * time.Hour, wantOK: true},
+		{name: "Weeks", expiry: "2w", want: 14 * 24 * time.Hour, wantOK: true},
+		{name: "Year", expiry: "1y", want: 365 * 24 * time.Hour, wantOK: true},
+		{name: "Never", expiry: "never", want: 0, wantOK: true},
+		{name: "Over a year", expiry: "53w", wantOK: false},
+		{name: "Huge", expiry: "99999999999999m", wantOK: false},
+		{name: "Zero", expiry: "0h", wantOK: false},
+		{name: "Negative", expiry: "-1d", wantOK: false},
+		{name: "No unit", expiry: "7", wantOK: false},
+		{name: "Unknown unit", expiry: "7s", wantOK: false},
+		{name: "Empty", expiry: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := parseExpiry(tt.expiry)
+			assert.Equal(t, ok, tt.wantOK)
+			assert.Equal(t, d, tt.want)
+		})
+	}
+}
+
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		snippet  *models.Snippet
+		wantName string
+	}{
+		{
+			name:     "Title and language",
+			snippet:  &models.Snippet{ShortID: "Xk3pQ9aZ1b", Title: "My Script!", Language: "bash"},
+			wantName: "my-script.sh",
+		},
+		{
+			name:     "Auto-detected language",
+			snippet:  &models.Snippet{ShortID: "Xk3pQ9aZ1b", Title: "notes_v2.final", Language: ""},
+			wantName: "notes_v2.final.txt",
+		},
+		{
+			name:     "Path characters",
+			snippet:  &models.Snippet{ShortID: "Xk3pQ9aZ1b", Title: "../../etc/passwd", Language: "go"},
+			wantName: "etc-passwd.go",
+		},
+		{
+			name:     "Nothing usable",
+			snippet:  &models.Snippet{ShortID: "Xk3pQ9aZ1b", Title: "日本語", Language: "python"},
+			wantName: "Xk3pQ9aZ1b.py",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, snippetFilename(tt.snippet), tt.wantName)
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &application{trustedProxies: proxies}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{name: "Direct", remoteAddr: "203.0.113.5:4321", want: "203.0.113.5"},
+		{name: "Untrusted proxy ignored", remoteAddr: "203.0.113.5:4321", forwardedFor: []string{"198.51.100.7"}, want: "203.0.113.5"},
+		{name: "Trusted proxy", remoteAddr: "192.0.2.1:4321", forwardedFor: []string{"198.51.100.7"}, want: "198.51.100.7"},
+		{name: "Trusted range", remoteAddr: "10.1.2.3:4321", forwardedFor: []string{"198.51.100.7"}, want: "198.51.100.7"},
+		{name: "Spoofed first hop", remoteAddr: "10.1.2.3:4321", forwardedFor: []string{"1.2.3.4, 198.51.100.7"}, want: "198.51.100.7"},
+		{name: "Chain of proxies", remoteAddr: "10.1.2.3:4321", forwardedFor: []string{"198.51.100.7, 10.9.9.9"}, want: "198.51.100.7"},
+		{name: "Repeated headers", remoteAddr: "10.1.2.3:4321", forwardedFor: []string{"198.51.100.7", "10.9.9.9"}, want: "198.51.100.7"},
+		{name: "All trusted", remoteAddr: "10.1.2.3:4321", forwardedFor: []string{"10.9.9.9"}, want: "10.9.9.9"},
+		{name: "Garbage", remoteAddr: "10.1.2.3:4321", forwardedFor: []string{"198.51.100.7, nonsense"}, want: "10.1.2.3"},
+		{name: "No header", remoteAddr: "10.1.2.3:4321", want: "10.1.2.3"},
+		{name: "IPv6", remoteAddr: "[2001:db8::1]:4321", forwardedFor: []string{"198.51.100.7"}, want: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			assert.Equal(t, app.clientIP(r), tt.want)
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := parseTrustedProxies("127.0.0.1,::1, 172.16.0.0/12,")
+	assert.NilError(t, err)
+	assert.Equal(t, len(prefixes), 3)
+	assert.Equal(t, prefixes[0].String(), "127.0.0.1/32")
+	assert.Equal(t, prefixes[1].String(), "::1/128")
+
+	_, err = parseTrustedProxies("localhost")
+	if err == nil {
+		t.Error("want an error for a host name")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// language is one of the choices in the language dropdown on the create page
+type language struct {
+	Name  string //chroma lexer name, stored in snippets.language
+	Label string //shown in the dropdown
+	Ext   string //file extension, without the dot
+}
+
+// languages users can pick from, an empty name means detect it from the content
+var languages = []language{
+	{Name: "", Label: "Auto-detect", Ext: "txt"},
+	{Name: "plaintext", Label: "Plain text", Ext: "txt"},
+	{Name: "bash", Label: "Bash", Ext: "sh"},
+	{Name: "c", Label: "C", Ext: "c"},
+	{Name: "cpp", Label: "C++", Ext: "cpp"},
+	{Name: "csharp", Label: "C#", Ext: "cs"},
+	{Name: "css", Label: "CSS", Ext: "css"},
+	{Name: "diff", Label: "Diff", Ext: "diff"},
+	{Name: "docker", Label: "Dockerfile", Ext: "dockerfile"},
+	{Name: "go", Label: "Go", Ext: "go"},
+	{Name: "html", Label: "HTML", Ext: "html"},
+	{Name: "java", Label: "Java", Ext: "java"},
+	{Name: "javascript", Label: "JavaScript", Ext: "js"},
+	{Name: "json", Label: "JSON", Ext: "json"},
+	{Name: "kotlin", Label: "Kotlin", Ext: "kt"},
+	{Name: "lua", Label: "Lua", Ext: "lua"},
+	{Name: "markdown", Label: "Markdown", Ext: "md"},
+	{Name: "php", Label: "PHP", Ext: "php"},
+	{Name: "powershell", Label: "PowerShell", Ext: "ps1"},
+	{Name: "python", Label: "Python", Ext: "py"},
+	{Name: "ruby", Label: "Ruby", Ext: "rb"},
+	{Name: "rust", Label: "Rust", Ext: "rs"},
+	{Name: "sql", Label: "SQL", Ext: "sql"},
+	{Name: "swift", Label: "Swift", Ext: "swift"},
+	{Name: "toml", Label: "TOML", Ext: "toml"},
+	{Name: "typescript", Label: "TypeScript", Ext: "ts"},
+	{Name: "xml", Label: "XML", Ext: "xml"},
+	{Name: "yaml", Label: "YAML", Ext: "yaml"},
+}
+
+// languageNames returns the names of every selectable language, for validator.PermittedValue
+func languageNames() []string {
+	names := make([]string, len(languages))
+	for i, l := range languages {
+		names[i] = l.Name
+	}
+	return names
+}
+
+// languageLabel returns the dropdown label for a language name, for showing on the view page
+func languageLabel(name string) string {
+	for _, l := range languages {
+		if l.Name == name {
+			return l.Label
+		}
+	}
+	return name
+}
+
+// languageExt returns the file extension for a language name, txt if it isn't one we know
+func languageExt(name string) string {
+	for _, l := range languages {
+		if l.Name == name {
+			return l.Ext
+		}
+	}
+	return "txt"
+}
+
+// highlightFormatter writes CSS classes rather than inline styles, the CSP in
+// secureHeaders doesn't allow inline styles. The classes are styled by
+// ui/static/css/chroma.css
+var highlightFormatter = html.New(html.WithClasses(true), html.PreventSurroundingPre(true))
+
+// highlightStyle is the theme chroma.css was generated from
+var highlightStyle = styles.Get("github")
+
+// highlight renders content as syntax highlighted HTML. If lang is empty or
+// unknown the language is guessed from the content, falling back to plain text.
+// The content is escaped by chroma, so the result is marked as trusted HTML
+// and html/template won't escape it a second time
+func highlight(content, lang string) (template.HTML, error) {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Analyse(content)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	//merge runs of tokens of the same type so the HTML is smaller
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	err = highlightFormatter.Format(&sb, highlightStyle, iterator)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(sb.String()), nil
+}
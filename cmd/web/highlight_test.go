@@ -0,0 +1,42 @@
+package main
+
+import (
+	"snippetbox/internal/assert"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		lang     string
+		wantBody string
+	}{
+		{
+			name:     "Go keyword",
+			content:  "func main() {}",
+			lang:     "go",
+			wantBody: `<span class="kd">func</span>`,
+		},
+		{
+			name:     "Plain text is escaped",
+			content:  "<script>alert(1)</script>",
+			lang:     "plaintext",
+			wantBody: "&lt;script&gt;alert(1)&lt;/script&gt;",
+		},
+		{
+			name:     "Unknown language falls back",
+			content:  "<b>hi</b>",
+			lang:     "nope",
+			wantBody: "&lt;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := highlight(tt.content, tt.lang)
+			assert.NilError(t, err)
+			assert.StringContains(t, string(got), tt.wantBody)
+		})
+	}
+}
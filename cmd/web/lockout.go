@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Failed logins are counted per account and per IP. After a few free tries
+// each failure doubles how long has to pass before the next try, and enough
+// failures lock logins out for a while. The per IP limits are looser as
+// people share addresses, they catch one client guessing across many accounts
+
+// failures older than this are forgotten
+const loginWindow = time.Hour
+
+type backoff struct {
+	free      int           //failures allowed before any waiting
+	base      time.Duration //wait after the first failure past free, doubled for each one after
+	lockAfter int           //failures that lock logins out
+	lockFor   time.Duration
+}
+
+var (
+	emailBackoff = backoff{free: 3, base: 5 * time.Second, lockAfter: 10, lockFor: 15 * time.Minute}
+	ipBackoff    = backoff{free: 20, base: 5 * time.Second, lockAfter: 30, lockFor: time.Hour}
+)
+
+// wait is how long after the last of failures the next try has to wait, and
+// whether that is a lockout rather than just back-off
+func (b backoff) wait(failures int) (time.Duration, bool) {
+	switch {
+	case failures >= b.lockAfter:
+		return b.lockFor, true
+	case failures < b.free:
+		return 0, false
+	}
+	//lockFor is reached long before 20 doublings, shifting much further could overflow
+	shift := min(failures-b.free, 20)
+	return min(b.base<<shift, b.lockFor), false
+}
+
+// loginWait is how long until email can try to log in from ip again, 0 if it
+// can now, and whether it's locked out rather than just slowed down
+func (app *application) loginWait(email, ip string) (time.Duration, bool, error) {
+	since := time.Now().Add(-loginWindow)
+
+	failures, last, err := app.loginAttempts.EmailFailures(email, since)
+	if err != nil {
+		return 0, false, err
+	}
+	wait, locked := emailBackoff.wait(failures)
+	remaining := time.Until(last.Add(wait))
+
+	failures, last, err = app.loginAttempts.IPFailures(ip, since)
+	if err != nil {
+		return 0, false, err
+	}
+	wait, ipLocked := ipBackoff.wait(failures)
+	if ipRemaining := time.Until(last.Add(wait)); ipRemaining > remaining {
+		remaining, locked = ipRemaining, ipLocked
+	}
+
+	if remaining <= 0 {
+		return 0, false, nil
+	}
+	return remaining, locked, nil
+}
+
+// lockoutMessage explains to someone at the login page why they have to wait
+func lockoutMessage(wait time.Duration, locked bool) string {
+	if locked {
+		return fmt.Sprintf("Too many failed logins, so logging in is locked for now. Try again in %s", humanWait(wait))
+	}
+	return fmt.Sprintf("Too many failed logins, wait %s before trying again", humanWait(wait))
+}
+
+// humanWait rounds a wait up to whole seconds or minutes, e.g. "40 seconds" or "15 minutes"
+func humanWait(d time.Duration) string {
+	if d <= time.Minute {
+		secs := int((d + time.Second - 1) / time.Second)
+		if secs == 1 {
+			return "1 second"
+		}
+		return fmt.Sprintf("%d seconds", secs)
+	}
+	mins := int((d + time.Minute - 1) / time.Minute)
+	return fmt.Sprintf("%d minutes", mins)
+}
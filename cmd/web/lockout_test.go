@@ -0,0 +1,58 @@
+package main
+
+import (
+	"snippetbox/internal/assert"
+	"testing"
+	"time"
+)
+
+func TestBackoffWait(t *testing.T) {
+	b := backoff{free: 3, base: 5 * time.Second, lockAfter: 10, lockFor: 15 * time.Minute}
+
+	tests := []struct {
+		name       string
+		failures   int
+		want       time.Duration
+		wantLocked bool
+	}{
+		{name: "None", failures: 0, want: 0},
+		{name: "Free", failures: 2, want: 0},
+		{name: "First back-off", failures: 3, want: 5 * time.Second},
+		{name: "Doubled", failures: 4, want: 10 * time.Second},
+		{name: "Doubled again", failures: 5, want: 20 * time.Second},
+		{name: "Last before lock", failures: 9, want: 320 * time.Second},
+		{name: "Locked", failures: 10, want: 15 * time.Minute, wantLocked: true},
+		{name: "Still locked", failures: 50, want: 15 * time.Minute, wantLocked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, locked := b.wait(tt.failures)
+			assert.Equal(t, wait, tt.want)
+			assert.Equal(t, locked, tt.wantLocked)
+		})
+	}
+
+	//a long way from free to lockAfter mustn't overflow into a short or negative wait
+	wide := backoff{free: 0, base: time.Second, lockAfter: 1000, lockFor: time.Hour}
+	wait, _ := wide.wait(999)
+	assert.Equal(t, wait, time.Hour)
+}
+
+func TestHumanWait(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 300 * time.Millisecond, want: "1 second"},
+		{d: 19*time.Second + time.Millisecond, want: "20 seconds"},
+		{d: time.Minute, want: "60 seconds"},
+		{d: 14*time.Minute + time.Second, want: "15 minutes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, humanWait(tt.d), tt.want)
+		})
+	}
+}
@@ -1,16 +1,25 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"flag"
+	"html/template"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
-	"text/template"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"snippetbox/internal/mailer"
 	"snippetbox/internal/models"
 
 	"github.com/alexedwards/scs/mysqlstore"
@@ -21,7 +30,18 @@ import (
 
 // Add a config struct
 type config struct {
-	DSN string `json:"dsn"`
+	DSN       string     `json:"dsn"`
+	SMTP      smtpConfig `json:"smtp"`       //optional, emails are written to stdout without it
+	SecretKey string     `json:"secret_key"` //signs emailed links, a random one is made if not set
+}
+
+// mail server to send password reset links and the like through
+type smtpConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"` //587 if not set
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"` //e.g. "Snippetbox <no-reply@example.com>"
 }
 
 //Main is used for runtime config, dependencies for handlers and HTTP running
@@ -33,15 +53,45 @@ type application struct {
 	infoLog        *log.Logger
 	snippets       models.SnippetModelInterface
 	users          models.UserModelInterface
+	tokens         models.TokenModelInterface
+	loginAttempts  models.LoginAttemptModelInterface
+	passwordResets models.PasswordResetModelInterface
+	twoFactor      models.TwoFactorModelInterface
+	userSessions   models.UserSessionModelInterface
+	mailer         mailer.Mailer
+	secretKey      []byte //for signing links, see sign
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	baseURL        string       //public URL of the site, used for links in plain text replies
+	pasteLimiter   *rateLimiter //per IP limit on pipe uploads
+	loginLimiter   *rateLimiter //per IP limit on login attempts
+	signupLimiter  *rateLimiter //per IP limit on signups
+	createLimiter  *rateLimiter //per user limit on creating snippets
+	mailLimiter    *rateLimiter //per IP limit on anything that sends an email
+	unlockLimiter  *rateLimiter //per IP limit on snippet password guesses
+	trustedProxies []netip.Prefix
+	wg             sync.WaitGroup //background jobs, main waits for them before closing the DB
 }
 
 func main() {
 	//remember ports 0-1023 are restricted
 	addr := flag.String("addr", ":4000", "HTTP network address")
 	// default value of 4000 set
+	// how often expired snippets are deleted and how many per DELETE
+	reapInterval := flag.Duration("reap-interval", 10*time.Minute, "How often to delete expired snippets")
+	reapBatch := flag.Int("reap-batch", 500, "Maximum expired snippets deleted per query")
+	// used for the links pipe uploads reply with, when behind a proxy the request Host may be wrong
+	baseURL := flag.String("base-url", "", "Public URL of the site, e.g. https://paste.example.com (default from the request)")
+	pasteRate := flag.Float64("paste-rate", 6, "Pipe uploads allowed per IP per minute")
+	pasteBurst := flag.Int("paste-burst", 10, "Pipe uploads an IP can make at once before paste-rate applies")
+	// requests from these are allowed to say who the client is in X-Forwarded-For
+	trustedProxies := flag.String("trusted-proxies", "", "Comma separated IPs and CIDR ranges of reverse proxies to trust X-Forwarded-For from")
+	// termbin style pastes over plain TCP, off unless an address is given
+	ncAddr := flag.String("nc-addr", "", "TCP address for netcat pastes, e.g. :9999 (default off)")
+	ncTimeout := flag.Duration("nc-timeout", 2*time.Second, "How long a netcat client can go quiet before its paste is saved")
+	ncExpires := flag.String("nc-expires", "1w", "How long netcat pastes live for: 10m, 1h, 1d, 1w or 1y")
+	ncConns := flag.Int("nc-conns", 2, "Netcat connections an IP can have open at once")
 
 	// dsn := flag.String("dsn", "web:auxwork@/snippetbox?parseTime=true", "MySQL data source name")
 	//	todo- change password, hide this, use Env
@@ -57,6 +107,19 @@ func main() {
 	}
 	flag.Parse() //Sanitizes the arg coming in just in case
 
+	//a batch of 0 never catches up and a negative one is LIMIT -1
+	if *reapInterval <= 0 {
+		log.Fatal("Invalid -reap-interval: must be more than 0")
+	}
+	if *reapBatch <= 0 {
+		log.Fatal("Invalid -reap-batch: must be more than 0")
+	}
+
+	proxies, err := parseTrustedProxies(*trustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid -trusted-proxies: %v", err)
+	}
+
 	//we really really would want env vars but the drawback is no default setting out of the box
 	//and no -help function
 
@@ -73,6 +136,15 @@ func main() {
 	if err != nil {
 		errorLog.Fatal(err)
 	}
+	secretKey := []byte(cfg.SecretKey)
+	if len(secretKey) == 0 {
+		secretKey = make([]byte, 32)
+		_, err = rand.Read(secretKey)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		infoLog.Print("No secret_key in config.json, links emailed before a restart won't work after it")
+	}
 	formDecoder := form.NewDecoder() //init decoder instance to add to below dependencies
 	//use new! scs to init session mgmer
 	//config to use mysql as store and expires in 48hrs
@@ -88,9 +160,42 @@ func main() {
 		infoLog:        infoLog,
 		snippets:       &models.SnippetModel{DB: db},
 		users:          &models.UserModel{DB: db},
+		tokens:         &models.TokenModel{DB: db},
+		loginAttempts:  &models.LoginAttemptModel{DB: db},
+		passwordResets: &models.PasswordResetModel{DB: db},
+		twoFactor:      &models.TwoFactorModel{DB: db},
+		userSessions:   &models.UserSessionModel{DB: db},
+		mailer:         newMailer(cfg.SMTP),
+		secretKey:      secretKey,
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		baseURL:        *baseURL,
+		pasteLimiter:   newRateLimiter(*pasteRate, *pasteBurst),
+		loginLimiter:   newRateLimiter(10, 5),  //plenty for mistyped passwords, too slow for guessing them
+		signupLimiter:  newRateLimiter(0.1, 3), //6 an hour, a household's worth rather than a bot farm's
+		createLimiter:  newRateLimiter(20, 10),
+		mailLimiter:    newRateLimiter(0.1, 3),
+		unlockLimiter:  newRateLimiter(10, 5), //as for logins, each guess is a bcrypt compare
+		trustedProxies: proxies,
+	}
+
+	// netcat has no request to take the host from, so links need -base-url
+	var ncListener net.Listener
+	var ncCfg netcatConfig
+	if *ncAddr != "" {
+		if *baseURL == "" {
+			errorLog.Fatal("-nc-addr needs -base-url for the links it replies with")
+		}
+		expires, ok := parseExpiry(*ncExpires)
+		if !ok || expires == 0 {
+			errorLog.Fatalf("-nc-expires %q must be a duration like 10m, 1h, 1d, 1w or 1y", *ncExpires)
+		}
+		ncCfg = netcatConfig{idleTimeout: *ncTimeout, expires: expires, maxConnsPerIP: *ncConns}
+		ncListener, err = net.Listen("tcp", *ncAddr)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
 	}
 	//below is a struct to hold non-default TLS settings for server to use
 	//want only elliptic curves used for performance
@@ -108,14 +213,64 @@ func main() {
 		WriteTimeout: 10 * time.Second, //10s,
 	}
 
+	// ctx is cancelled on Ctrl+C or SIGTERM (docker stop), which starts a clean shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app.background(func() {
+		app.reapExpiredSnippets(ctx, *reapInterval, *reapBatch)
+	})
+	if ncListener != nil {
+		infoLog.Printf("Starting netcat listener on %s", ncListener.Addr())
+		app.background(func() {
+			app.serveNetcat(ctx, ncListener, ncCfg)
+		})
+	}
+
+	// Shutdown makes ListenAndServeTLS return straight away, so the result is
+	// passed back on a channel to wait for in-flight requests to finish
+	shutdownErr := make(chan error)
+	go func() {
+		<-ctx.Done()
+		infoLog.Print("Shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		shutdownErr <- srv.Shutdown(shutdownCtx)
+	}()
+
 	infoLog.Printf("Starting server on %s", *addr)
 	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem") //required for HTTPS
-	errorLog.Fatal(err)
+	if !errors.Is(err, http.ErrServerClosed) {
+		errorLog.Fatal(err)
+	}
+	if err = <-shutdownErr; err != nil {
+		errorLog.Print(err)
+	}
+	app.wg.Wait()
+	infoLog.Print("Stopped server")
 
 	//Set Cache control header, if another Cache-Control header exists this will overwrite it
 
 }
 
+// newMailer sends through the SMTP server in the config, or just prints emails
+// to stdout when there isn't one so reset links can be copied while developing
+func newMailer(cfg smtpConfig) mailer.Mailer {
+	if cfg.Host == "" {
+		return &mailer.Log{Out: os.Stdout}
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	return &mailer.SMTP{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     cfg.From,
+	}
+}
+
 // OpenDB() function wraps sql.open and returns the sql.DB connection pool
 func openDB(dsn string) (*sql.DB, error) {
 	db, err := sql.Open("mysql", dsn) //sql.open dosent create any connections, just inits a pool
@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"snippetbox/internal/models"
+	"strconv"
 
 	"github.com/justinas/nosurf"
 )
@@ -59,6 +63,20 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireAPIAuthentication is requireAuthentication for the API, a JSON 401
+// instead of a redirect to the login page
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.apiError(w, http.StatusUnauthorized, "you need to be logged in or send an API token to do that")
+			return
+		}
+		w.Header().Add("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
 // below is to prevent crosssite attacks
 // uses a custom CSRF cookie with secure, path and httponly attributes set
 func noSurf(next http.Handler) http.Handler {
@@ -80,19 +98,120 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
-		//otherwisecheck if user exists in our DB
-		exists, err := app.users.Exists(id)
+		//otherwise check the session is still signed in. Its row in
+		//user_sessions goes when it is signed out from another device, and
+		//when the user is deleted, so this checks the user exists too
+		ok, err := app.userSessions.Touch(app.sessionManager.GetInt(r.Context(), "sessionID"), id, app.clientIP(r))
 		if err != nil {
 			app.serverError(w, err)
 			return
 		}
-		//if matching user found, then req is coming from auth user that exists
-		//create new copy of req and assign to r
-		if exists {
-			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
-			r = r.WithContext(ctx)
+		if !ok {
+			app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+			app.sessionManager.Remove(r.Context(), "sessionID")
+			next.ServeHTTP(w, r)
+			return
 		}
+		//req is coming from auth user that exists
+		//create new copy of req and assign to r
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
+		r = r.WithContext(ctx)
 		//call next handler
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requireVerifiedEmail sends users who haven't followed the link emailed on
+// signup to the verify page. Goes after requireAuthentication in a chain
+func (app *application) requireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verified, err := app.emailVerified(r)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if !verified {
+			app.sessionManager.Put(r.Context(), "flash", "Verify your email address before creating snippets")
+			http.Redirect(w, r, "/account/verify", http.StatusSeeOther)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAPIVerifiedEmail is requireVerifiedEmail for the JSON API
+func (app *application) requireAPIVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verified, err := app.emailVerified(r)
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		if !verified {
+			app.apiError(w, http.StatusForbidden, "verify your email address before creating snippets")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticateToken logs in API requests sent with an "Authorization: Bearer"
+// token, the same way authenticate does for sessions. A bad token is turned
+// away rather than ignored, so the client finds out straight away
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := app.bearerToken(r)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				app.apiError(w, http.StatusUnauthorized, "invalid or expired API token")
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+		//no token, the session may still have logged the request in
+		if token == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserIDContextKey, token.UserID)
+		ctx = context.WithValue(ctx, apiTokenContextKey, token)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireScope turns away requests authenticated with an API token that
+// wasn't given scope. Browser sessions aren't limited by scopes
+func (app *application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := r.Context().Value(apiTokenContextKey).(*models.Token)
+			if ok && !token.HasScope(scope) {
+				app.apiError(w, http.StatusForbidden, fmt.Sprintf("API token needs the %s scope", scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitRate turns away clients that go over the limit of l with a 429, keyed
+// by user ID when logged in and IP address otherwise. It has to come after
+// authenticate in a chain for the user ID to be known
+func (app *application) limitRate(l *rateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, wait := l.allow(app.rateLimitKey(r))
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				app.clientError(w, http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
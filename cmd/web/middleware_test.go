@@ -2,10 +2,12 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"snippetbox/internal/assert"
+	"snippetbox/internal/models/mocks"
 	"testing"
 )
 
@@ -72,3 +74,71 @@ func TestSecureHeaders(t *testing.T) {
 
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestLimitRate(t *testing.T) {
+	app := newTestApplication(t)
+	handler := app.limitRate(newRateLimiter(1, 1))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+
+	//each logged in user gets their own budget, even from the same IP
+	steps := []struct {
+		name     string
+		userID   int
+		wantCode int
+	}{
+		{name: "Anonymous", wantCode: http.StatusOK},
+		{name: "Anonymous again", wantCode: http.StatusTooManyRequests},
+		{name: "User 1", userID: 1, wantCode: http.StatusOK},
+		{name: "User 1 again", userID: 1, wantCode: http.StatusTooManyRequests},
+		{name: "User 2", userID: 2, wantCode: http.StatusOK},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			if step.userID != 0 {
+				r = r.WithContext(context.WithValue(r.Context(), authenticatedUserIDContextKey, step.userID))
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, step.wantCode)
+			if step.wantCode == http.StatusTooManyRequests {
+				assert.Equal(t, rr.Header().Get("Retry-After"), "60")
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name      string
+		sessionID int
+		want      bool
+	}{
+		{name: "Signed in", sessionID: 1, want: true},
+		{name: "Signed out elsewhere", sessionID: mocks.MockRevokedSessionID},
+		{name: "No session row", sessionID: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			var authenticated bool
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authenticated = app.isAuthenticated(r)
+			})
+			//the session is set up as a login would leave it, then checked
+			handler := app.sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				app.sessionManager.Put(r.Context(), "authenticatedUserID", 1)
+				app.sessionManager.Put(r.Context(), "sessionID", tt.sessionID)
+				app.authenticate(next).ServeHTTP(w, r)
+			}))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, authenticated, tt.want)
+		})
+	}
+}
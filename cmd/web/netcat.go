@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"snippetbox/internal/models"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Pastes over plain TCP, in the style of termbin
+//
+//	echo hello | nc host 9999
+//
+// Whatever is sent becomes an anonymous unlisted snippet and the URL is written
+// back. The paste ends when the client closes its side, or stops sending for
+// idleTimeout. Nothing is read back from the client, so there are no options
+
+// netcatMaxDuration is how long a client gets to send a whole paste, so a slow
+// drip of bytes can't keep a connection open forever
+const netcatMaxDuration = 30 * time.Second
+
+type netcatConfig struct {
+	idleTimeout   time.Duration //a pause this long ends the paste
+	expires       time.Duration //how long netcat pastes live for
+	maxConnsPerIP int
+}
+
+// serveNetcat accepts paste connections on ln until ctx is cancelled, then
+// waits for the connections it has open to finish. Meant to be run in its own
+// goroutine from main()
+func (app *application) serveNetcat(ctx context.Context, ln net.Listener, cfg netcatConfig) {
+	conns := newConnLimiter(cfg.maxConnsPerIP)
+	var wg sync.WaitGroup
+
+	//Accept only returns once the listener is closed
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				break
+			}
+			//e.g. out of file descriptors, back off rather than spin
+			app.errorLog.Printf("netcat accept: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		//counted here rather than in the goroutine so connections are limited in the order they arrive
+		ip := remoteIP(conn)
+		if !conns.acquire(ip) {
+			netcatReply(conn, "error: too many connections from your address")
+			conn.Close()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conns.release(ip)
+			app.handleNetcat(conn, ip, cfg)
+		}()
+	}
+	wg.Wait()
+	app.infoLog.Print("Stopped netcat listener")
+}
+
+// handleNetcat stores one paste and replies with its URL, or a line starting
+// with "error:" saying why it wasn't stored
+func (app *application) handleNetcat(conn net.Conn, ip string, cfg netcatConfig) {
+	defer conn.Close()
+
+	//the same budget as HTTP pipe uploads, so switching protocol doesn't get around it
+	if ok, wait := app.pasteLimiter.allow(ip); !ok {
+		netcatReply(conn, fmt.Sprintf("error: too many pastes, try again in %d seconds", int(wait.Seconds())+1))
+		return
+	}
+
+	content, err := readNetcat(conn, cfg.idleTimeout)
+	if err != nil {
+		netcatReply(conn, "error: "+err.Error())
+		return
+	}
+
+	snippet := &models.Snippet{
+		Title:      "Untitled",
+		Content:    content,
+		Expires:    expiresAt(cfg.expires),
+		Visibility: models.VisibilityUnlisted,
+	}
+	shortID, err := app.snippets.Insert(snippet, "")
+	if err != nil {
+		app.errorLog.Printf("netcat paste from %s: %v", ip, err)
+		netcatReply(conn, "error: the paste couldn't be saved, try again later")
+		return
+	}
+	netcatReply(conn, strings.TrimRight(app.baseURL, "/")+"/snippet/view/"+shortID)
+}
+
+// readNetcat reads a paste until EOF or until the client goes quiet for
+// idleTimeout. Plain nc doesn't close its side when stdin ends, so the pause
+// is what normally ends a paste
+func readNetcat(conn net.Conn, idleTimeout time.Duration) (string, error) {
+	deadline := time.Now().Add(netcatMaxDuration)
+	data := make([]byte, 0, 4096)
+	buf := make([]byte, 4096)
+	for {
+		conn.SetReadDeadline(minTime(time.Now().Add(idleTimeout), deadline))
+		n, err := conn.Read(buf)
+		data = append(data, buf[:n]...)
+		if len(data) > maxPasteBytes {
+			return "", errPasteTooLarge
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				return "", err
+			}
+			//a pause ends the paste, but dripping bytes past netcatMaxDuration doesn't
+			if !time.Now().Before(deadline) {
+				return "", fmt.Errorf("took too long, pastes have to be sent within %s", netcatMaxDuration)
+			}
+			break
+		}
+	}
+
+	if strings.TrimSpace(string(data)) == "" {
+		return "", errors.New("nothing to paste")
+	}
+	if !utf8.Valid(data) {
+		return "", errors.New("only UTF-8 text can be pasted")
+	}
+	return string(data), nil
+}
+
+// remoteIP is the address a connection came from, without the port
+func remoteIP(conn net.Conn) string {
+	ip, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return ip
+}
+
+// netcatReply writes one line back, without waiting long for a client that isn't reading
+func netcatReply(conn net.Conn, line string) {
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintln(conn, line)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// connLimiter caps how many connections each IP can have open at once
+type connLimiter struct {
+	max  int
+	mu   sync.Mutex
+	open map[string]int
+}
+
+func newConnLimiter(max int) *connLimiter {
+	return &connLimiter{max: max, open: make(map[string]int)}
+}
+
+// acquire reports whether ip can open another connection, and if so counts it
+// until release is called
+func (l *connLimiter) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.open[ip] >= l.max {
+		return false
+	}
+	l.open[ip]++
+	return true
+}
+
+func (l *connLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.open[ip]--
+	//keep the map to the IPs that are actually connected
+	if l.open[ip] <= 0 {
+		delete(l.open, ip)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"snippetbox/internal/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startNetcat runs serveNetcat on a loopback port until the test ends
+func startNetcat(t *testing.T, cfg netcatConfig) string {
+	app := newTestApplication(t)
+	app.baseURL = "https://paste.example.com"
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		app.serveNetcat(ctx, ln, cfg)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return ln.Addr().String()
+}
+
+func dialNetcat(t *testing.T, addr string) *net.TCPConn {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn.(*net.TCPConn)
+}
+
+func TestNetcat(t *testing.T) {
+	addr := startNetcat(t, netcatConfig{idleTimeout: 100 * time.Millisecond, expires: time.Hour, maxConnsPerIP: 10})
+
+	tests := []struct {
+		name      string
+		data      string
+		keepOpen  bool //like plain nc, which doesn't close its side at the end of stdin
+		wantReply string
+	}{
+		{
+			name:      "Closed by client",
+			data:      "echo hello\n",
+			wantReply: "https://paste.example.com/snippet/view/Nw4sE6yH0j\n",
+		},
+		{
+			name:      "Ended by pause",
+			data:      "echo hello\n",
+			keepOpen:  true,
+			wantReply: "https://paste.example.com/snippet/view/Nw4sE6yH0j\n",
+		},
+		{
+			name:      "Nothing sent",
+			keepOpen:  true,
+			wantReply: "error: nothing to paste\n",
+		},
+		{
+			name:      "Blank",
+			data:      " \n\n",
+			wantReply: "error: nothing to paste\n",
+		},
+		{
+			name:      "Binary",
+			data:      "\xff\xfe\x00",
+			wantReply: "error: only UTF-8 text can be pasted\n",
+		},
+		{
+			name:      "Too large",
+			data:      strings.Repeat("a", maxPasteBytes+1),
+			wantReply: "error: " + errPasteTooLarge.Error() + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := dialNetcat(t, addr)
+			_, err := io.WriteString(conn, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.keepOpen {
+				conn.CloseWrite()
+			}
+
+			reply, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, reply, tt.wantReply)
+		})
+	}
+}
+
+func TestNetcatConnLimit(t *testing.T) {
+	addr := startNetcat(t, netcatConfig{idleTimeout: time.Second, expires: time.Hour, maxConnsPerIP: 1})
+
+	//the first connection stays open waiting for its paste
+	first := dialNetcat(t, addr)
+	io.WriteString(first, "still typing")
+
+	second := dialNetcat(t, addr)
+	reply, err := bufio.NewReader(second).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, reply, "error: too many connections from your address\n")
+
+	//and the first one still gets its paste saved
+	first.CloseWrite()
+	reply, err = bufio.NewReader(first).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, reply, "https://paste.example.com/snippet/view/Nw4sE6yH0j\n")
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"snippetbox/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Pipe uploads for terminals, in the style of sprunge and ix.io
+//
+//	curl -F 'f=@-' https://host/ < file
+//	curl --data-binary @file https://host/p
+//
+// The reply is just the URL of the new snippet. Uploads are anonymous unless
+// an API token with the write scope is sent, and are unlisted by default. The
+// title, expires, visibility, lang and burn query parameters work like the
+// fields of the create form
+
+// maxPasteBytes is as much as fits in the TEXT content column
+const maxPasteBytes = 65535
+
+// maxPasteOverhead is room for the multipart headers around the upload
+const maxPasteOverhead = 16 << 10
+
+var errPasteTooLarge = errors.New("paste is too large, the limit is 64KB")
+
+func (app *application) pastePost(w http.ResponseWriter, r *http.Request) {
+	token, err := app.bearerToken(r)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			http.Error(w, "invalid or expired API token", http.StatusUnauthorized)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if token != nil && !token.HasScope(models.ScopeWrite) {
+		http.Error(w, "API token needs the write scope", http.StatusForbidden)
+		return
+	}
+	//as in the API, pastes made with a token need a verified email
+	if token != nil {
+		user, err := app.users.Get(token.UserID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if !user.EmailVerified {
+			http.Error(w, "verify your email address before creating snippets", http.StatusForbidden)
+			return
+		}
+	}
+
+	content, filename, err := readPaste(w, r)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.Is(err, errPasteTooLarge) || errors.As(err, &maxBytesError) {
+			http.Error(w, errPasteTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	query := r.URL.Query()
+	burn, _ := strconv.ParseBool(query.Get("burn"))
+	form := snippetCreateForm{
+		Title:            query.Get("title"),
+		Content:          content,
+		Expires:          query.Get("expires"),
+		Visibility:       query.Get("visibility"),
+		BurnAfterReading: burn,
+		Language:         query.Get("lang"),
+	}
+	if form.Title == "" {
+		form.Title = filename
+	}
+	if form.Title == "" {
+		form.Title = "Untitled"
+	}
+	if form.Expires == "" {
+		form.Expires = "1w"
+	}
+	if form.Visibility == "" {
+		form.Visibility = models.VisibilityUnlisted
+	}
+
+	expires := form.validate()
+	//nobody could ever see an anonymous private snippet, or delete an anonymous one that never expires
+	if token == nil {
+		form.CheckField(form.Visibility != models.VisibilityPrivate, "visibility", "Anonymous pastes can't be private")
+		form.CheckField(form.Expires != "never", "expires", "Anonymous pastes have to expire")
+	}
+	if !form.Valid() {
+		http.Error(w, fieldErrorsText(form.FieldErrors), http.StatusBadRequest)
+		return
+	}
+
+	snippet := &models.Snippet{
+		Title:            form.Title,
+		Content:          form.Content,
+		Expires:          expiresAt(expires),
+		Visibility:       form.Visibility,
+		BurnAfterReading: form.BurnAfterReading,
+		Language:         form.Language,
+	}
+	if token != nil {
+		snippet.UserID = token.UserID
+	}
+	shortID, err := app.snippets.Insert(snippet, "")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	url := app.absoluteURL(r, fmt.Sprintf("/snippet/view/%s", shortID))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Location", url)
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, url)
+}
+
+// readPaste returns the uploaded text and its file name if it has one. The
+// text is the f field of a multipart form, or else the whole request body
+func readPaste(w http.ResponseWriter, r *http.Request) (string, string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasteBytes+maxPasteOverhead)
+
+	var data []byte
+	var filename string
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		err := r.ParseMultipartForm(maxPasteBytes + maxPasteOverhead)
+		if err != nil {
+			return "", "", err
+		}
+		//-F 'f=@file' sends a file, -F 'f=<file' a plain field
+		file, header, err := r.FormFile("f")
+		if err == nil {
+			defer file.Close()
+			data, err = io.ReadAll(file)
+			if err != nil {
+				return "", "", err
+			}
+			//curl calls stdin "-"
+			if header.Filename != "-" {
+				filename = header.Filename
+			}
+		} else if errors.Is(err, http.ErrMissingFile) {
+			data = []byte(r.FormValue("f"))
+		} else {
+			return "", "", err
+		}
+	} else {
+		var err error
+		data, err = io.ReadAll(r.Body)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if len(data) > maxPasteBytes {
+		return "", "", errPasteTooLarge
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return "", "", errors.New("nothing to paste, send the text as the request body or the f form field")
+	}
+	if !utf8.Valid(data) {
+		return "", "", errors.New("only UTF-8 text can be pasted")
+	}
+	return string(data), filename, nil
+}
+
+// fieldErrorsText lists validation errors one per line for plain text replies
+func fieldErrorsText(fieldErrors map[string]string) string {
+	fields := make([]string, 0, len(fieldErrors))
+	for field := range fieldErrors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var sb strings.Builder
+	for i, field := range fields {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "%s: %s", field, fieldErrors[field])
+	}
+	return sb.String()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"snippetbox/internal/assert"
+	"snippetbox/internal/models/mocks"
+	"strings"
+	"testing"
+)
+
+// multipartPaste builds a body like curl -F 'f=@-' sends, or -F 'f=<-' with no filename
+func multipartPaste(t *testing.T, filename, content string) (string, string) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	var err error
+	if filename != "" {
+		var fw io.Writer
+		fw, err = mw.CreateFormFile("f", filename)
+		if err == nil {
+			_, err = io.WriteString(fw, content)
+		}
+	} else {
+		err = mw.WriteField("f", content)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	mw.Close()
+	return mw.FormDataContentType(), buf.String()
+}
+
+func TestPastePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	wantURL := ts.URL + "/snippet/view/Nw4sE6yH0j\n"
+	fileType, fileBody := multipartPaste(t, "-", "echo hello\n")
+	fieldType, fieldBody := multipartPaste(t, "", "echo hello\n")
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		contentType string
+		body        string
+		token       string
+		wantCode    int
+		wantBody    string
+	}{
+		{
+			name:     "Raw body",
+			urlPath:  "/p",
+			body:     "echo hello\n",
+			wantCode: http.StatusCreated,
+			wantBody: wantURL,
+		},
+		{
+			name:        "Multipart file",
+			urlPath:     "/",
+			contentType: fileType,
+			body:        fileBody,
+			wantCode:    http.StatusCreated,
+			wantBody:    wantURL,
+		},
+		{
+			name:        "Multipart field",
+			urlPath:     "/",
+			contentType: fieldType,
+			body:        fieldBody,
+			wantCode:    http.StatusCreated,
+			wantBody:    wantURL,
+		},
+		{
+			name:     "With options",
+			urlPath:  "/p?lang=bash&expires=1d&title=hello.sh",
+			body:     "echo hello\n",
+			wantCode: http.StatusCreated,
+			wantBody: wantURL,
+		},
+		{
+			name:     "Empty",
+			urlPath:  "/p",
+			body:     " \n",
+			wantCode: http.StatusBadRequest,
+			wantBody: "nothing to paste",
+		},
+		{
+			name:     "Too large",
+			urlPath:  "/p",
+			body:     strings.Repeat("a", maxPasteBytes+1),
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantBody: "paste is too large",
+		},
+		{
+			name:     "Binary",
+			urlPath:  "/p",
+			body:     "\xff\xfe\x00\x01",
+			wantCode: http.StatusBadRequest,
+			wantBody: "only UTF-8 text can be pasted",
+		},
+		{
+			name:     "Invalid expiry",
+			urlPath:  "/p?expires=7",
+			body:     "echo hello\n",
+			wantCode: http.StatusBadRequest,
+			wantBody: "expires: Field must be a duration",
+		},
+		{
+			name:     "Anonymous private",
+			urlPath:  "/p?visibility=private",
+			body:     "echo hello\n",
+			wantCode: http.StatusBadRequest,
+			wantBody: "visibility: Anonymous pastes can't be private",
+		},
+		{
+			name:     "Anonymous never expiring",
+			urlPath:  "/p?expires=never",
+			body:     "echo hello\n",
+			wantCode: http.StatusBadRequest,
+			wantBody: "expires: Anonymous pastes have to expire",
+		},
+		{
+			name:     "Token private never expiring",
+			urlPath:  "/p?visibility=private&expires=never",
+			body:     "echo hello\n",
+			token:    mocks.MockToken,
+			wantCode: http.StatusCreated,
+			wantBody: wantURL,
+		},
+		{
+			name:     "Read only token",
+			urlPath:  "/p",
+			body:     "echo hello\n",
+			token:    mocks.MockReadOnlyToken,
+			wantCode: http.StatusForbidden,
+			wantBody: "API token needs the write scope",
+		},
+		{
+			name:     "Unverified email",
+			urlPath:  "/p",
+			body:     "echo hello\n",
+			token:    mocks.MockUnverifiedToken,
+			wantCode: http.StatusForbidden,
+			wantBody: "verify your email address before creating snippets",
+		},
+		{
+			name:     "Bad token",
+			urlPath:  "/p",
+			body:     "echo hello\n",
+			token:    "sb_nope",
+			wantCode: http.StatusUnauthorized,
+			wantBody: "invalid or expired API token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+tt.urlPath, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			//what curl --data-binary sends
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+			body, err := io.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.StringContains(t, string(body), tt.wantBody)
+		})
+	}
+}
+
+func TestPastePostRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	app.pasteLimiter = newRateLimiter(1, 2)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	for i, wantCode := range []int{http.StatusCreated, http.StatusCreated, http.StatusTooManyRequests} {
+		rs, err := ts.Client().Post(ts.URL+"/p", "text/plain", strings.NewReader("echo hello\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		assert.Equal(t, rs.StatusCode, wantCode)
+		if i == 2 {
+			assert.Equal(t, rs.Header.Get("Retry-After"), "60")
+		}
+	}
+}
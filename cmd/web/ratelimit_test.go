@@ -0,0 +1,47 @@
+package main
+
+import (
+	"snippetbox/internal/assert"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(6, 2) //one every 10 seconds
+	l.now = func() time.Time { return now }
+
+	steps := []struct {
+		name     string
+		after    time.Duration
+		key      string
+		want     bool
+		wantWait time.Duration
+	}{
+		{name: "First of burst", key: "a", want: true},
+		{name: "Second of burst", key: "a", want: true},
+		{name: "Burst used up", key: "a", want: false, wantWait: 10 * time.Second},
+		{name: "Other client", key: "b", want: true},
+		{name: "Half refilled", after: 5 * time.Second, key: "a", want: false, wantWait: 5 * time.Second},
+		{name: "Refilled", after: 5 * time.Second, key: "a", want: true},
+		{name: "Refill is capped at burst", after: time.Hour, key: "a", want: true},
+		{name: "Still capped", key: "a", want: true},
+		{name: "Capped burst used up", key: "a", want: false, wantWait: 10 * time.Second},
+	}
+
+	//the steps share the limiter, so they run in order
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			now = now.Add(step.after)
+			ok, wait := l.allow(step.key)
+			assert.Equal(t, ok, step.want)
+			assert.Equal(t, wait, step.wantWait)
+		})
+	}
+
+	//the hour that went by means b has been forgotten, a was seen just now
+	_, ok := l.clients["b"]
+	assert.Equal(t, ok, false)
+	_, ok = l.clients["a"]
+	assert.Equal(t, ok, true)
+}
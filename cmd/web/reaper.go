@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// reapExpiredSnippets deletes expired snippets every interval until ctx is cancelled.
+// Get and Latest already hide expired rows, this just stops the table growing forever.
+// Meant to be run in its own goroutine from main()
+func (app *application) reapExpiredSnippets(ctx context.Context, interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			app.infoLog.Print("Stopped expired snippet reaper")
+			return
+		case <-ticker.C:
+			deleted, err := app.reapOnce(ctx, batchSize)
+			if err != nil {
+				app.errorLog.Printf("reaping expired snippets: %v", err)
+			}
+			if deleted > 0 {
+				app.infoLog.Printf("Reaped %d expired snippets", deleted)
+			}
+		}
+	}
+}
+
+// reapOnce deletes expired snippets in batches of batchSize until there are none left
+// or ctx is cancelled, returning the total deleted
+func (app *application) reapOnce(ctx context.Context, batchSize int) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		deleted, err := app.snippets.DeleteExpired(batchSize)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		//a short batch means we've caught up
+		if deleted < batchSize {
+			break
+		}
+	}
+	return total, nil
+}
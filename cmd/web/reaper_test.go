@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"snippetbox/internal/assert"
+	"snippetbox/internal/models/mocks"
+	"testing"
+)
+
+func TestReapOnce(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		expired     int
+		batchSize   int
+		wantDeleted int
+		wantLeft    int
+	}{
+		{name: "Nothing expired", ctx: context.Background(), batchSize: 100},
+		{name: "One short batch", ctx: context.Background(), expired: 30, batchSize: 100, wantDeleted: 30},
+		{name: "Several batches", ctx: context.Background(), expired: 250, batchSize: 100, wantDeleted: 250},
+		{name: "Exact batches", ctx: context.Background(), expired: 200, batchSize: 100, wantDeleted: 200},
+		{name: "Cancelled", ctx: cancelled, expired: 250, batchSize: 100, wantLeft: 250},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			snippets := &mocks.SnippetModel{Expired: tt.expired}
+			app.snippets = snippets
+
+			deleted, err := app.reapOnce(tt.ctx, tt.batchSize)
+			assert.NilError(t, err)
+			assert.Equal(t, deleted, tt.wantDeleted)
+			assert.Equal(t, snippets.Expired, tt.wantLeft)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"net/http"
+	"snippetbox/internal/models"
 	"snippetbox/ui"
 
 	"github.com/julienschmidt/httprouter"
@@ -35,21 +36,84 @@ func (app *application) routes() http.Handler {
 	// handlers.
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodPost, "/snippet/view/:id", dynamic.ThenFunc(app.snippetBurnPost))
+	router.Handler(http.MethodPost, "/snippet/unlock/:id", dynamic.Append(app.limitRate(app.unlockLimiter)).ThenFunc(app.snippetUnlockPost))
+	router.Handler(http.MethodGet, "/snippet/view/:id/history", dynamic.ThenFunc(app.snippetHistory))
+	router.Handler(http.MethodGet, "/snippet/view/:id/diff", dynamic.ThenFunc(app.snippetDiff))
+	router.Handler(http.MethodGet, "/snippet/raw/:id", dynamic.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/download/:id", dynamic.ThenFunc(app.snippetDownload))
 	//	router.Handler(http.MethodGet, "/snippet/create", dynamic.ThenFunc(app.snippetCreate))
 	//	router.Handler(http.MethodPost, "/snippet/create", dynamic.ThenFunc(app.snippetCreatePost))
 
 	//Five new routes w/ middleware for logging in users
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
-	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
+	router.Handler(http.MethodPost, "/user/signup", dynamic.Append(app.limitRate(app.signupLimiter)).ThenFunc(app.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
-	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
+	router.Handler(http.MethodPost, "/user/login", dynamic.Append(app.limitRate(app.loginLimiter)).ThenFunc(app.userLoginPost))
+	router.Handler(http.MethodGet, "/user/password/forgot", dynamic.ThenFunc(app.userForgot))
+	router.Handler(http.MethodPost, "/user/password/forgot", dynamic.Append(app.limitRate(app.mailLimiter)).ThenFunc(app.userForgotPost))
+	router.Handler(http.MethodGet, "/user/password/reset/:token", dynamic.ThenFunc(app.userReset))
+	router.Handler(http.MethodPost, "/user/password/reset/:token", dynamic.ThenFunc(app.userResetPost))
+	router.Handler(http.MethodGet, "/user/verify/:token", dynamic.ThenFunc(app.userVerify))
+	router.Handler(http.MethodGet, "/user/login/2fa", dynamic.ThenFunc(app.userLogin2FA))
+	router.Handler(http.MethodPost, "/user/login/2fa", dynamic.Append(app.limitRate(app.loginLimiter)).ThenFunc(app.userLogin2FAPost))
 	//	router.Handler(http.MethodPost, "/user/logout", dynamic.ThenFunc(app.userLogoutPost))
 
 	// protected auth only app routes w/ middleware chain
 	protected := dynamic.Append(app.requireAuthentication)
-	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	// only users who have verified their email can create snippets
+	verified := protected.Append(app.requireVerifiedEmail)
+	router.Handler(http.MethodGet, "/snippet/create", verified.ThenFunc(app.snippetCreate))
+	router.Handler(http.MethodPost, "/snippet/create", verified.Append(app.limitRate(app.createLimiter)).ThenFunc(app.snippetCreatePost))
+	router.Handler(http.MethodGet, "/snippet/edit/:id", protected.ThenFunc(app.snippetEdit))
+	router.Handler(http.MethodPost, "/snippet/edit/:id", protected.ThenFunc(app.snippetEditPost))
+	router.Handler(http.MethodPost, "/snippet/extend/:id", protected.ThenFunc(app.snippetExtendPost))
+	router.Handler(http.MethodPost, "/snippet/delete/:id", protected.ThenFunc(app.snippetDeletePost))
+	router.Handler(http.MethodGet, "/user/snippets", protected.ThenFunc(app.userSnippets))
+	router.Handler(http.MethodGet, "/account/tokens", protected.ThenFunc(app.accountTokens))
+	router.Handler(http.MethodPost, "/account/tokens", protected.ThenFunc(app.accountTokensPost))
+	router.Handler(http.MethodPost, "/account/tokens/revoke/:id", protected.ThenFunc(app.accountTokenRevokePost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
+	router.Handler(http.MethodGet, "/account", protected.ThenFunc(app.account))
+	router.Handler(http.MethodPost, "/account/name", protected.ThenFunc(app.accountNamePost))
+	// both check the current password, so they are limited like logins
+	router.Handler(http.MethodPost, "/account/email", protected.Append(app.limitRate(app.mailLimiter)).ThenFunc(app.accountEmailPost))
+	router.Handler(http.MethodPost, "/account/password", protected.Append(app.limitRate(app.loginLimiter)).ThenFunc(app.accountPasswordPost))
+	router.Handler(http.MethodGet, "/account/export", protected.ThenFunc(app.accountExport))
+	router.Handler(http.MethodGet, "/account/delete", protected.ThenFunc(app.accountDelete))
+	router.Handler(http.MethodPost, "/account/delete", protected.Append(app.limitRate(app.loginLimiter)).ThenFunc(app.accountDeletePost))
+	router.Handler(http.MethodGet, "/account/sessions", protected.ThenFunc(app.accountSessions))
+	router.Handler(http.MethodPost, "/account/sessions/revoke/:id", protected.ThenFunc(app.accountSessionRevokePost))
+	router.Handler(http.MethodPost, "/account/sessions/revoke-others", protected.ThenFunc(app.accountSessionsRevokeOthersPost))
+	router.Handler(http.MethodGet, "/account/2fa", protected.ThenFunc(app.accountTwoFactor))
+	router.Handler(http.MethodPost, "/account/2fa/setup", protected.ThenFunc(app.accountTwoFactorSetupPost))
+	router.Handler(http.MethodGet, "/account/2fa/qr.png", protected.ThenFunc(app.accountTwoFactorQR))
+	router.Handler(http.MethodPost, "/account/2fa/enable", protected.Append(app.limitRate(app.loginLimiter)).ThenFunc(app.accountTwoFactorEnablePost))
+	router.Handler(http.MethodPost, "/account/2fa/disable", protected.Append(app.limitRate(app.loginLimiter)).ThenFunc(app.accountTwoFactorDisablePost))
+	router.Handler(http.MethodGet, "/account/verify", protected.ThenFunc(app.accountVerify))
+	router.Handler(http.MethodPost, "/account/verify", protected.Append(app.limitRate(app.mailLimiter)).ThenFunc(app.accountVerifyPost))
+
+	// JSON API, versioned so it can change without breaking clients. It skips
+	// noSurf as there are no forms to put a token in, readJSON only taking
+	// JSON bodies is what keeps cross site requests out instead. Clients log
+	// in with an API token, or the session cookie when used from a browser
+	api := alice.New(app.sessionManager.LoadAndSave, app.authenticate, app.authenticateToken)
+	apiRead := api.Append(app.requireScope(models.ScopeRead))
+	router.Handler(http.MethodGet, "/api/v1/snippets", apiRead.ThenFunc(app.apiSnippetList))
+	router.Handler(http.MethodGet, "/api/v1/snippets/:id", apiRead.ThenFunc(app.apiSnippetView))
+	router.Handler(http.MethodPost, "/api/v1/snippets/:id/burn", apiRead.ThenFunc(app.apiSnippetBurn))
+
+	apiProtected := api.Append(app.requireAPIAuthentication)
+	router.Handler(http.MethodGet, "/api/v1/user/snippets", apiProtected.Append(app.requireScope(models.ScopeRead)).ThenFunc(app.apiUserSnippets))
+	router.Handler(http.MethodPost, "/api/v1/snippets", apiProtected.Append(app.requireScope(models.ScopeWrite), app.requireAPIVerifiedEmail, app.limitRate(app.createLimiter)).ThenFunc(app.apiSnippetCreate))
+	router.Handler(http.MethodPatch, "/api/v1/snippets/:id", apiProtected.Append(app.requireScope(models.ScopeWrite)).ThenFunc(app.apiSnippetUpdate))
+	router.Handler(http.MethodDelete, "/api/v1/snippets/:id", apiProtected.Append(app.requireScope(models.ScopeDelete)).ThenFunc(app.apiSnippetDelete))
+
+	// pipe uploads from curl. There is no session or CSRF token to check, so
+	// they are kept to a few per IP instead
+	paste := alice.New(app.limitRate(app.pasteLimiter))
+	router.Handler(http.MethodPost, "/", paste.ThenFunc(app.pastePost))
+	router.Handler(http.MethodPost, "/p", paste.ThenFunc(app.pastePost))
 
 	// Create the middleware chain as normal.
 	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
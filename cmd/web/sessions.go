@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"snippetbox/internal/models"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// accountSessions lists everywhere the user is logged in, so a lost laptop or
+// a login they don't recognise can be signed out
+func (app *application) accountSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := app.userSessions.ListByUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Sessions = sessions
+	data.SessionID = app.sessionManager.GetInt(r.Context(), "sessionID")
+	app.render(w, http.StatusOK, "sessions.tmpl", data)
+}
+
+// accountSessionRevokePost signs out one session. It takes effect on that
+// session's next request, see authenticate
+func (app *application) accountSessionRevokePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.userSessions.Delete(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Session signed out")
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
+
+// accountSessionsRevokeOthersPost signs out every session but this one
+func (app *application) accountSessionsRevokeOthersPost(w http.ResponseWriter, r *http.Request) {
+	err := app.userSessions.DeleteOthers(app.authenticatedUserID(r), app.sessionManager.GetInt(r.Context(), "sessionID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "You've been signed out everywhere else")
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
+
+// the first match wins, so browsers built on others come before them (Edge
+// and Opera say they are Chrome, Chrome says it is Safari) and iPhones before
+// macOS as they say they are "like Mac OS X"
+var (
+	userAgentBrowsers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	}
+	userAgentSystems = []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// describeUserAgent turns a User-Agent header into something like "Firefox on
+// Windows" for the sessions page. Ones it doesn't know are shown as they are
+func describeUserAgent(ua string) string {
+	var browser, system string
+	for _, b := range userAgentBrowsers {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, s := range userAgentSystems {
+		if strings.Contains(ua, s.token) {
+			system = s.name
+			break
+		}
+	}
+
+	switch {
+	case browser == "" && ua == "":
+		return "Unknown"
+	case browser == "":
+		return ua
+	case system == "":
+		return browser
+	default:
+		return browser + " on " + system
+	}
+}
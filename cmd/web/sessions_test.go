@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"snippetbox/internal/assert"
+	"testing"
+)
+
+func TestAccountSessions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	code, _, body := ts.get(t, "/account/sessions")
+	assert.Equal(t, code, http.StatusOK)
+	//the login got session 1, so that's the one marked as this session
+	assert.StringContains(t, body, "This session")
+	assert.StringContains(t, body, "<td title='Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:128.0) Gecko/20100101 Firefox/128.0'>Firefox on Windows</td>")
+	assert.StringContains(t, body, "<td>203.0.113.9</td>")
+	assert.StringContains(t, body, "<form action='/account/sessions/revoke/2' method='POST'>")
+	assert.StringContains(t, body, "Sign out everywhere else")
+}
+
+func TestAccountSessionRevokePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	csrfToken := ts.csrfToken(t, "/account/sessions")
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Other session",
+			urlPath:      "/account/sessions/revoke/2",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/sessions",
+		},
+		{
+			name:         "Everywhere else",
+			urlPath:      "/account/sessions/revoke-others",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/sessions",
+		},
+		{
+			name:     "Someone else's session",
+			urlPath:  "/account/sessions/revoke/7",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			urlPath:  "/account/sessions/revoke/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestDescribeUserAgent(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want string
+	}{
+		{
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0",
+			want: "Edge on Windows",
+		},
+		{
+			ua:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+			want: "Chrome on macOS",
+		},
+		{
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+			want: "Safari on iPhone",
+		},
+		{
+			ua:   "Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36",
+			want: "Chrome on Android",
+		},
+		{
+			ua:   "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0",
+			want: "Firefox on Linux",
+		},
+		{ua: "curl/8.5.0", want: "curl"},
+		{ua: "Go-http-client/1.1", want: "Go-http-client/1.1"},
+		{ua: "", want: "Unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, describeUserAgent(tt.ua), tt.want)
+		})
+	}
+}
@@ -1,11 +1,12 @@
 package main
 
 import (
+	"html/template"
 	"io/fs"
 	"path/filepath"
+	"snippetbox/internal/diff"
 	"snippetbox/internal/models"
 	"snippetbox/ui"
-	"text/template"
 	"time"
 )
 
@@ -13,13 +14,34 @@ import (
 //Go has a limit of one per page, this allows us to do way more
 
 type templateData struct {
-	CurrentYear     int
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet //including a snippets field to hold a slice of snippets
-	Form            any               //Used to pass validation errors back to template when re-display form so users dont have to enter it again
-	Flash           string            //added for sessionmanager stuff
-	IsAuthenticated bool              //used in helper.go
-	CSRFToken       string            //used in preventing attacks,
+	CurrentYear         int
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet //including a snippets field to hold a slice of snippets
+	Revisions           []*models.Revision
+	Diff                *revisionDiff
+	Tokens              []*models.Token
+	User                *models.User
+	NewToken            string //plaintext of a token just created, only ever shown once
+	TwoFactor           *models.TwoFactor
+	OTPAuthURI          string   //for setting up an authenticator app by hand
+	RecoveryCodes       []string //plaintext two-factor recovery codes, only shown once when it's turned on
+	RecoveryCodesLeft   int
+	Sessions            []*models.UserSession
+	SessionID           int    //ID of the session the page is for, to mark it in Sessions
+	Form                any    //Used to pass validation errors back to template when re-display form so users dont have to enter it again
+	Flash               string //added for sessionmanager stuff
+	IsAuthenticated     bool   //used in helper.go
+	AuthenticatedUserID int    //0 when logged out, lets templates check snippet ownership
+	CSRFToken           string //used in preventing attacks,
+}
+
+// revisionDiff holds the two revisions being compared on the diff page and the changes between them
+type revisionDiff struct {
+	From  *models.Revision
+	To    *models.Revision
+	Hunks []diff.Hunk
+	// set instead of Hunks when the revisions are too big or different to compare
+	TooDifferent bool
 }
 
 // Formating a nicer string for time
@@ -34,7 +56,11 @@ func humanDate(t time.Time) string {
 
 // init a funcmap object and store it in global var
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":     humanDate,
+	"highlight":     highlight,
+	"languageLabel": languageLabel,
+	"userAgent":     describeUserAgent,
+	"languages":     func() []language { return languages },
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"snippetbox/internal/assert"
+	"snippetbox/internal/models"
+	"strings"
 	"testing"
 	"time"
 )
@@ -41,3 +44,52 @@ func TestHumanDate(t *testing.T) {
 	}
 
 }
+
+// snippet titles and content are user input, every page showing them must escape them
+func TestTemplatesEscapeSnippets(t *testing.T) {
+	cache, err := newTemplateCache()
+	assert.NilError(t, err)
+
+	hostile := &models.Snippet{
+		ShortID:    "Xk3pQ9aZ1b",
+		Title:      "<script>alert('title')</script>",
+		Content:    "<script>alert('content')</script>",
+		Created:    time.Now(),
+		Visibility: models.VisibilityPublic,
+		Language:   "plaintext",
+	}
+
+	tests := []struct {
+		name string
+		page string
+		data *templateData
+	}{
+		{
+			name: "View",
+			page: "view.tmpl",
+			data: &templateData{Snippet: hostile},
+		},
+		{
+			name: "Home",
+			page: "home.tmpl",
+			data: &templateData{Snippets: []*models.Snippet{hostile}},
+		},
+		{
+			name: "My snippets",
+			page: "snippets.tmpl",
+			data: &templateData{Snippets: []*models.Snippet{hostile}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			err := cache[tt.page].ExecuteTemplate(buf, "base", tt.data)
+			assert.NilError(t, err)
+
+			body := buf.String()
+			assert.StringContains(t, body, "&lt;script&gt;alert(&#39;title&#39;)&lt;/script&gt;")
+			assert.Equal(t, strings.Contains(body, "<script>alert("), false)
+		})
+	}
+}
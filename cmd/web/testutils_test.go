@@ -8,8 +8,11 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
 	"regexp"
+	"snippetbox/internal/mailer"
 	"snippetbox/internal/models/mocks"
+	"strings"
 	"testing"
 	"time"
 
@@ -50,9 +53,22 @@ func newTestApplication(t *testing.T) *application {
 		infoLog:        log.New(io.Discard, "", 0),
 		snippets:       &mocks.SnippetModel{}, //use mocker
 		users:          &mocks.UserModel{},
+		tokens:         &mocks.TokenModel{},
+		loginAttempts:  &mocks.LoginAttemptModel{},
+		passwordResets: &mocks.PasswordResetModel{},
+		twoFactor:      &mocks.TwoFactorModel{},
+		userSessions:   &mocks.UserSessionModel{},
+		mailer:         &mailer.Log{Out: io.Discard},
+		secretKey:      []byte("test secret key"),
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		pasteLimiter:   newRateLimiter(60, 100),
+		loginLimiter:   newRateLimiter(60, 100),
+		signupLimiter:  newRateLimiter(60, 100),
+		createLimiter:  newRateLimiter(60, 100),
+		mailLimiter:    newRateLimiter(60, 100),
+		unlockLimiter:  newRateLimiter(60, 100),
 	}
 }
 
@@ -95,3 +111,82 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, strin
 	bytes.TrimSpace(body)
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// postForm() method sends POST requests to the test server with the form data
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
+	req, err := http.NewRequest(http.MethodPost, ts.URL+urlPath, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	//nosurf checks the request comes from the same origin as well as the token
+	req.Header.Set("Origin", ts.URL)
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytes.TrimSpace(body)
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+// sendJSON() makes an API request with body as the JSON request body, an empty body sends none
+func (ts *testServer) sendJSON(t *testing.T, method, urlPath, body string, header http.Header) (int, http.Header, string) {
+	var rb io.Reader
+	if body != "" {
+		rb = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, ts.URL+urlPath, rb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	rsBody, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, string(rsBody)
+}
+
+// login() signs in as the mocked user with ID 1 so protected routes can be tested,
+// the session cookie ends up in the client cookie jar
+func (ts *testServer) login(t *testing.T) {
+	ts.loginAs(t, "alice@example.com")
+}
+
+// loginAs() signs in as one of the other mocked users
+func (ts *testServer) loginAs(t *testing.T, email string) {
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", code)
+	}
+}
+
+// csrfToken() fetches a page and returns the CSRF token embedded in it
+func (ts *testServer) csrfToken(t *testing.T, urlPath string) string {
+	_, _, body := ts.get(t, urlPath)
+	return extractCSRFToken(t, body)
+}
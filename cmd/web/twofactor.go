@@ -0,0 +1,363 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"snippetbox/internal/models"
+	"snippetbox/internal/totp"
+	"snippetbox/internal/validator"
+	"strconv"
+	"time"
+
+	"rsc.io/qr"
+)
+
+// Two-factor logins use an authenticator app (RFC 6238 TOTP). Set up is in
+// two steps, the secret is saved first then only turned on once a code from
+// the app has been checked against it. Logging in with it on is in two steps
+// too, see userLoginPost and userLogin2FAPost
+
+const (
+	// shown in authenticator apps next to the account's email
+	twoFactorIssuer = "Snippetbox"
+	// how long someone has after getting their password right to give a code
+	twoFactorLoginTTL = 5 * time.Minute
+)
+
+// used both to turn two-factor logins on, with a code from the app, and to
+// turn them off, without one
+type twoFactorForm struct {
+	Code                string `form:"code"`
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// the second step of logging in, Code is from the app or a recovery code
+type userLogin2FAForm struct {
+	Code                string `form:"code"`
+	validator.Validator `form:"-"`
+}
+
+// accountTwoFactor shows whether two-factor logins are on, and during set up
+// the QR code to scan
+func (app *application) accountTwoFactor(w http.ResponseWriter, r *http.Request) {
+	app.renderTwoFactor(w, r, http.StatusOK, twoFactorForm{}, nil)
+}
+
+// renderTwoFactor shows the two-factor page with form. recoveryCodes are only
+// passed in just after it has been turned on, it's the one time they are shown
+func (app *application) renderTwoFactor(w http.ResponseWriter, r *http.Request, status int, form twoFactorForm, recoveryCodes []string) {
+	id := app.authenticatedUserID(r)
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	tf, err := app.twoFactor.Get(id)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Form = form
+	data.RecoveryCodes = recoveryCodes
+	if tf != nil {
+		data.TwoFactor = tf
+		data.OTPAuthURI = totp.URI(tf.Secret, twoFactorIssuer, user.Email)
+		if tf.Enabled {
+			data.RecoveryCodesLeft, err = app.twoFactor.RecoveryCodesLeft(id)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+		}
+	}
+	app.render(w, status, "twofactor.tmpl", data)
+}
+
+// accountTwoFactorSetupPost starts setting up two-factor logins with a new
+// secret. Starting again replaces the secret, so a QR code that was shown
+// before stops working
+func (app *application) accountTwoFactorSetupPost(w http.ResponseWriter, r *http.Request) {
+	id := app.authenticatedUserID(r)
+	tf, err := app.twoFactor.Get(id)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+	if tf != nil && tf.Enabled {
+		http.Redirect(w, r, "/account/2fa", http.StatusSeeOther)
+		return
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.twoFactor.Begin(id, secret)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/account/2fa", http.StatusSeeOther)
+}
+
+// accountTwoFactorQR is the otpauth:// URI of a set up in progress as a QR
+// code. It's drawn here rather than by a third party as the secret is in it
+func (app *application) accountTwoFactorQR(w http.ResponseWriter, r *http.Request) {
+	id := app.authenticatedUserID(r)
+	tf, err := app.twoFactor.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	//once it's on the secret is never shown again
+	if tf.Enabled {
+		app.notFound(w)
+		return
+	}
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	code, err := qr.Encode(totp.URI(tf.Secret, twoFactorIssuer, user.Email), qr.M)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	code.Scale = 5
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(code.PNG())
+}
+
+// accountTwoFactorEnablePost turns two-factor logins on once a code from the
+// app checks out, and shows the recovery codes
+func (app *application) accountTwoFactorEnablePost(w http.ResponseWriter, r *http.Request) {
+	var form twoFactorForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id := app.authenticatedUserID(r)
+	tf, err := app.twoFactor.Get(id)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+	if tf == nil || tf.Enabled {
+		http.Redirect(w, r, "/account/2fa", http.StatusSeeOther)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	if form.Valid() {
+		err = app.users.CheckPassword(id, form.Password)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, err)
+				return
+			}
+			form.AddFieldError("password", "Password is incorrect")
+		}
+	}
+	counter, ok := totp.Validate(tf.Secret, form.Code, time.Now())
+	if form.Valid() && !ok {
+		form.AddFieldError("code", "Code is incorrect, check the clock on your device is right")
+	}
+	if !form.Valid() {
+		app.renderTwoFactor(w, r, http.StatusUnprocessableEntity, form, nil)
+		return
+	}
+
+	codes, err := app.twoFactor.Enable(id, counter)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.renderTwoFactor(w, r, http.StatusOK, twoFactorForm{}, codes)
+}
+
+func (app *application) accountTwoFactorDisablePost(w http.ResponseWriter, r *http.Request) {
+	var form twoFactorForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id := app.authenticatedUserID(r)
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	if form.Valid() {
+		err = app.users.CheckPassword(id, form.Password)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, err)
+				return
+			}
+			form.AddFieldError("password", "Password is incorrect")
+		}
+	}
+	if !form.Valid() {
+		app.renderTwoFactor(w, r, http.StatusUnprocessableEntity, form, nil)
+		return
+	}
+
+	err = app.twoFactor.Disable(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "Two-factor login is off")
+	http.Redirect(w, r, "/account/2fa", http.StatusSeeOther)
+}
+
+// twoFactorPending returns the user who got their password right in this
+// session and still has to give a code, 0 if there isn't one or they took
+// too long
+func (app *application) twoFactorPending(r *http.Request) int {
+	expires := app.sessionManager.GetInt64(r.Context(), "twoFactorExpires")
+	if time.Now().Unix() > expires {
+		return 0
+	}
+	return app.sessionManager.GetInt(r.Context(), "twoFactorUserID")
+}
+
+func (app *application) userLogin2FA(w http.ResponseWriter, r *http.Request) {
+	if app.twoFactorPending(r) == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+	data := app.newTemplateData(r)
+	data.Form = userLogin2FAForm{}
+	app.render(w, http.StatusOK, "login2fa.tmpl", data)
+}
+
+// userLogin2FAPost is the second step of logging in. Wrong codes count as
+// failed logins to the account, so they are slowed down and locked out the
+// same way as guessing the password is
+func (app *application) userLogin2FAPost(w http.ResponseWriter, r *http.Request) {
+	id := app.twoFactorPending(r)
+	if id == 0 {
+		app.sessionManager.Put(r.Context(), "flash", "Your login timed out, please log in again")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	var form userLogin2FAForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login2fa.tmpl", data)
+		return
+	}
+
+	//the account may have been deleted since the password was given
+	user, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	ip := app.clientIP(r)
+	wait, locked, err := app.loginWait(user.Email, ip)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if wait > 0 {
+		err = app.loginAttempts.Insert(user.Email, ip, models.LoginBlocked)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		form.AddNonFieldError(lockoutMessage(wait, locked))
+		data := app.newTemplateData(r)
+		data.Form = form
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		app.render(w, http.StatusTooManyRequests, "login2fa.tmpl", data)
+		return
+	}
+
+	recovery, ok, err := app.checkTwoFactorCode(id, form.Code)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !ok {
+		err = app.loginAttempts.Insert(user.Email, ip, models.LoginFailure)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		form.AddFieldError("code", "Code is incorrect")
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login2fa.tmpl", data)
+		return
+	}
+
+	if recovery {
+		left, err := app.twoFactor.RecoveryCodesLeft(id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You logged in with a recovery code, you have %d left", left))
+	}
+	app.logIn(w, r, user.Email, id)
+}
+
+// checkTwoFactorCode checks code from the second step of logging in, which
+// is either 6 digits from the app or one of the recovery codes. recovery is
+// true if it was a recovery code, which is used up
+func (app *application) checkTwoFactorCode(id int, code string) (recovery, ok bool, err error) {
+	tf, err := app.twoFactor.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+
+	if len(code) <= 7 {
+		counter, ok := totp.Validate(tf.Secret, code, time.Now())
+		if !ok {
+			return false, false, nil
+		}
+		err = app.twoFactor.UseCounter(id, counter)
+		if errors.Is(err, models.ErrCodeUsed) {
+			return false, false, nil
+		}
+		return false, err == nil, err
+	}
+
+	err = app.twoFactor.UseRecoveryCode(id, code)
+	if errors.Is(err, models.ErrNoRecord) {
+		return true, false, nil
+	}
+	return true, err == nil, err
+}
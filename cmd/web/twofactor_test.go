@@ -0,0 +1,185 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"snippetbox/internal/assert"
+	"snippetbox/internal/models/mocks"
+	"snippetbox/internal/totp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccountTwoFactor(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	//alice has started setting up but not finished
+	ts.login(t)
+	code, _, body := ts.get(t, "/account/2fa")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<img src='/account/2fa/qr.png'")
+	assert.StringContains(t, body, "otpauth://totp/Snippetbox:alice@example.com?")
+	assert.StringContains(t, body, mocks.MockTOTPSecret)
+
+	code, header, body := ts.get(t, "/account/2fa/qr.png")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "image/png")
+	assert.Equal(t, header.Get("Cache-Control"), "no-store")
+	assert.Equal(t, strings.HasPrefix(body, "\x89PNG"), true)
+}
+
+func TestAccountTwoFactorEnablePost(t *testing.T) {
+	validCode, err := totp.Code(mocks.MockTOTPSecret, time.Now())
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name     string
+		code     string
+		password string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid",
+			code:     validCode,
+			password: "pa$$word",
+			wantCode: http.StatusOK,
+			wantBody: mocks.MockRecoveryCode,
+		},
+		{
+			name:     "Wrong code",
+			code:     "000000",
+			password: "pa$$word",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Code is incorrect",
+		},
+		{
+			name:     "Wrong password",
+			code:     validCode,
+			password: "wrong",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Password is incorrect",
+		},
+		{
+			name:     "Blank code",
+			password: "pa$$word",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+	}
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	csrfToken := ts.csrfToken(t, "/account/2fa")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("code", tt.code)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/account/2fa/enable", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestUserLogin2FAPost(t *testing.T) {
+	validCode, err := totp.Code(mocks.MockTOTPSecret, time.Now())
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name     string
+		code     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "App code",
+			code:     validCode,
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Recovery code",
+			code:     strings.ToUpper(mocks.MockRecoveryCode),
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Wrong code",
+			code:     "000000",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Code is incorrect",
+		},
+		{
+			name:     "Wrong recovery code",
+			code:     "aaaa-bbbb-cccc-dddd",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Code is incorrect",
+		},
+		{
+			name:     "Blank code",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			//the password alone only gets as far as the code page
+			form := url.Values{}
+			form.Add("email", "dave@example.com")
+			form.Add("password", "pa$$word")
+			form.Add("csrf_token", ts.csrfToken(t, "/user/login"))
+			code, header, _ := ts.postForm(t, "/user/login", form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, header.Get("Location"), "/user/login/2fa")
+
+			code, header, _ = ts.get(t, "/account")
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, header.Get("Location"), "/user/login")
+
+			form = url.Values{}
+			form.Add("code", tt.code)
+			form.Add("csrf_token", ts.csrfToken(t, "/user/login/2fa"))
+			code, header, body := ts.postForm(t, "/user/login/2fa", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+			if code != http.StatusSeeOther {
+				return
+			}
+			assert.Equal(t, header.Get("Location"), "/snippet/create")
+
+			code, _, _ = ts.get(t, "/account")
+			assert.Equal(t, code, http.StatusOK)
+		})
+	}
+}
+
+func TestUserLogin2FANotPending(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.get(t, "/user/login/2fa")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	form := url.Values{}
+	form.Add("code", "123456")
+	form.Add("csrf_token", ts.csrfToken(t, "/user/login"))
+	code, header, _ = ts.postForm(t, "/user/login/2fa", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"snippetbox/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Email verification links are signed rather than stored: the token is the
+// user ID, email and expiry, with an HMAC of them using the secret key. The
+// email being in it means a link stops working if the address is changed
+
+// how long an emailed verification link works for
+const emailVerifyTTL = 7 * 24 * time.Hour
+
+var errInvalidVerifyToken = errors.New("invalid or expired verification token")
+
+// emailVerifyToken signs a verification token for the user's current email
+func (app *application) emailVerifyToken(userID int, email string, expires time.Time) string {
+	payload := fmt.Sprintf("%d|%d|%s", userID, expires.Unix(), email)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(app.sign("verify-email", payload))
+}
+
+// parseEmailVerifyToken checks the signature and expiry of a token, returning
+// the user ID and email it was made for
+func (app *application) parseEmailVerifyToken(token string, now time.Time) (int, string, error) {
+	encPayload, encMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, "", errInvalidVerifyToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return 0, "", errInvalidVerifyToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encMAC)
+	if err != nil {
+		return 0, "", errInvalidVerifyToken
+	}
+	if !hmac.Equal(mac, app.sign("verify-email", string(payload))) {
+		return 0, "", errInvalidVerifyToken
+	}
+
+	//the email goes last as it is the only part that could have a | in it
+	fields := strings.SplitN(string(payload), "|", 3)
+	if len(fields) != 3 {
+		return 0, "", errInvalidVerifyToken
+	}
+	userID, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, "", errInvalidVerifyToken
+	}
+	expires, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || now.Unix() > expires {
+		return 0, "", errInvalidVerifyToken
+	}
+	return userID, fields[2], nil
+}
+
+// sign is an HMAC-SHA256 of msg with the secret key. purpose keeps a signature
+// made for one thing from being passed off as one for another
+func (app *application) sign(purpose, msg string) []byte {
+	h := hmac.New(sha256.New, app.secretKey)
+	h.Write([]byte(purpose + "\x00" + msg))
+	return h.Sum(nil)
+}
+
+// sendVerifyEmail emails the user a link to verify their address
+func (app *application) sendVerifyEmail(r *http.Request, user *models.User) error {
+	token := app.emailVerifyToken(user.ID, user.Email, time.Now().Add(emailVerifyTTL))
+	link := app.absoluteURL(r, "/user/verify/"+token)
+	return app.mailer.Send(user.Email, "Verify your Snippetbox email address", fmt.Sprintf(verifyEmail, user.Name, link))
+}
+
+const verifyEmail = `Hi %s,
+
+Thanks for signing up to Snippetbox. Follow this link to verify your email
+address, then you can start creating snippets:
+
+%s
+
+If you didn't sign up, ignore this email and the account won't be usable.
+`
+
+// userVerify follows the link from the verification email. It works without
+// being logged in, people often open it on another device
+func (app *application) userVerify(w http.ResponseWriter, r *http.Request) {
+	token := httprouter.ParamsFromContext(r.Context()).ByName("token")
+	userID, email, err := app.parseEmailVerifyToken(token, time.Now())
+	if err == nil {
+		err = app.users.VerifyEmail(userID, email)
+	}
+	if err != nil {
+		if !errors.Is(err, errInvalidVerifyToken) && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "flash", "That verification link is invalid or has expired, log in to get a new one")
+		http.Redirect(w, r, "/account/verify", http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Thanks, your email address is verified")
+	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+}
+
+// accountVerify tells a logged in user their email still needs verifying,
+// with a button to send the link again
+func (app *application) accountVerify(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if user.EmailVerified {
+		http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	app.render(w, http.StatusOK, "verify.tmpl", data)
+}
+
+func (app *application) accountVerifyPost(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !user.EmailVerified {
+		err = app.sendVerifyEmail(r, user)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "flash", "A new verification link is on its way to "+user.Email)
+	}
+	http.Redirect(w, r, "/account/verify", http.StatusSeeOther)
+}
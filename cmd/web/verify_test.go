@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"regexp"
+	"snippetbox/internal/assert"
+	"snippetbox/internal/mailer"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailVerifyToken(t *testing.T) {
+	app := &application{secretKey: []byte("test secret key")}
+	now := time.Date(2024, 3, 17, 10, 0, 0, 0, time.UTC)
+	token := app.emailVerifyToken(3, "carol|x@example.com", now.Add(time.Hour))
+
+	userID, email, err := app.parseEmailVerifyToken(token, now)
+	assert.NilError(t, err)
+	assert.Equal(t, userID, 3)
+	assert.Equal(t, email, "carol|x@example.com")
+
+	other := &application{secretKey: []byte("another key")}
+	forged := other.emailVerifyToken(1, "alice@example.com", now.Add(time.Hour))
+	//alice's payload with carol's valid signature
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(token, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		now   time.Time
+	}{
+		{name: "Expired", token: token, now: now.Add(2 * time.Hour)},
+		{name: "Other key", token: forged, now: now},
+		{name: "Swapped payload", token: forgedPayload + "." + signature, now: now},
+		{name: "No signature", token: "MXwxfGFsaWNlQGV4YW1wbGUuY29t", now: now},
+		{name: "Garbage", token: "!!.!!", now: now},
+		{name: "Empty", token: "", now: now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := app.parseEmailVerifyToken(tt.token, tt.now)
+			assert.Equal(t, err, errInvalidVerifyToken)
+		})
+	}
+}
+
+// verifyLinkRX finds the link in a verification email
+var verifyLinkRX = regexp.MustCompile(`/user/verify/[A-Za-z0-9_.-]+`)
+
+func TestEmailVerification(t *testing.T) {
+	app := newTestApplication(t)
+	var sent bytes.Buffer
+	app.mailer = &mailer.Log{Out: &sent}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	//signing up sends the link
+	form := url.Values{}
+	form.Add("name", "Dave")
+	form.Add("email", "dave@example.com")
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", ts.csrfToken(t, "/user/signup"))
+	code, _, _ := ts.postForm(t, "/user/signup", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.StringContains(t, sent.String(), "To: dave@example.com")
+	assert.StringContains(t, sent.String(), "Hi Dave")
+	if !verifyLinkRX.MatchString(sent.String()) {
+		t.Fatalf("no verification link in %q", sent.String())
+	}
+
+	//an unverified user can't create snippets yet
+	ts.loginAs(t, "carol@example.com")
+	code, header, _ := ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/verify")
+
+	code, _, body := ts.get(t, "/account/verify")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "carol@example.com")
+
+	//but can get the link sent again
+	sent.Reset()
+	form = url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ = ts.postForm(t, "/account/verify", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.StringContains(t, sent.String(), "To: carol@example.com")
+	link := verifyLinkRX.FindString(sent.String())
+
+	code, header, _ = ts.get(t, link)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/snippet/create")
+
+	code, header, _ = ts.get(t, link+"x")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/verify")
+}
+
+func TestAPISnippetCreateUnverified(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "carol@example.com")
+	code, _, body := ts.sendJSON(t, http.MethodPost, "/api/v1/snippets", `{"title": "Hi", "content": "hello"}`, nil)
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.StringContains(t, body, "verify your email address")
+}
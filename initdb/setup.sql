@@ -4,20 +4,6 @@ CREATE DATABASE IF NOT EXISTS snippetbox;
 -- Select the database to use for the following statements.
 USE snippetbox;
 
--- Create the snippets table.
--- Using `TEXT` is a good choice for the content field.
-CREATE TABLE IF NOT EXISTS snippets (
-    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
-    title VARCHAR(100) NOT NULL,
-    content TEXT NOT NULL,
-    created DATETIME NOT NULL,
-    expires DATETIME NOT NULL
-);
-
--- Add a non-unique index on the `created` column to improve query performance
--- when ordering by creation date.
-CREATE INDEX idx_snippets_created ON snippets(created);
-
 -- Create a user with limited privileges for the web application.
 -- This is a great security practice.
 CREATE USER IF NOT EXISTS 'web'@'localhost';
@@ -36,12 +22,153 @@ CREATE TABLE IF NOT EXISTS users (
     name VARCHAR(255) NOT NULL,
     email VARCHAR(255) NOT NULL,
     hashed_password CHAR(60) NOT NULL,
-    created DATETIME NOT NULL
+    created DATETIME NOT NULL,
+    -- Set once the link emailed on signup has been followed.
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 -- Add a unique constraint on the `email` column to prevent duplicate user accounts.
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
 
+-- Create the snippets table.
+-- Using `TEXT` is a good choice for the content field.
+CREATE TABLE IF NOT EXISTS snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    -- Random public identifier used in URLs, the numeric id never leaves the app.
+    -- Binary collation so IDs differing only in case are distinct.
+    short_id CHAR(10) CHARACTER SET ascii COLLATE ascii_bin NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    -- Last time the title or content changed, sent as Last-Modified by the raw endpoints.
+    updated DATETIME NOT NULL,
+    -- NULL for snippets that never expire.
+    expires DATETIME,
+    -- Owner of the snippet. Left NULL if the owning account is removed.
+    user_id INTEGER,
+    -- public snippets are listed on the home page, unlisted ones need the link
+    -- and private ones can only be seen by their owner.
+    visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'public',
+    -- Deleted the first time someone reads it.
+    burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+    -- bcrypt hash of the optional snippet password, empty when there isn't one.
+    hashed_password CHAR(60) NOT NULL DEFAULT '',
+    -- Syntax highlighting language, empty to detect it from the content.
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    CONSTRAINT snippets_uc_short_id UNIQUE (short_id),
+    CONSTRAINT snippets_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- Add a non-unique index on the `created` column to improve query performance
+-- when ordering by creation date.
+CREATE INDEX idx_snippets_created ON snippets(created);
+
+-- Index the expires column too, the reaper looks up expired snippets with it.
+CREATE INDEX idx_snippets_expires ON snippets(expires);
+
+-- Create the snippet_revisions table.
+-- Every version of a snippet is kept here, numbered from 1 per snippet. The
+-- snippets table always holds a copy of the newest one.
+CREATE TABLE IF NOT EXISTS snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT snippet_revisions_fk_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+    CONSTRAINT snippet_revisions_uc_revision UNIQUE (snippet_id, revision)
+);
+
+-- Create the api_tokens table.
+-- Personal tokens for API clients. Only a SHA-256 of the token is kept, the
+-- token itself is shown once when it is created.
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token_hash BINARY(32) NOT NULL,
+    scopes SET('read', 'write', 'delete') NOT NULL,
+    created DATETIME NOT NULL,
+    -- NULL until the token is first used.
+    last_used DATETIME,
+    -- NULL for tokens that never expire.
+    expires DATETIME,
+    CONSTRAINT api_tokens_uc_token_hash UNIQUE (token_hash),
+    CONSTRAINT api_tokens_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create the password_resets table.
+-- One time tokens emailed out to reset a password. Only a SHA-256 of the token
+-- is kept, and every token of a user is deleted once one of them is used.
+CREATE TABLE IF NOT EXISTS password_resets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash BINARY(32) NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    CONSTRAINT password_resets_uc_token_hash UNIQUE (token_hash),
+    CONSTRAINT password_resets_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create the user_totp table.
+-- Authenticator app secrets for two-factor logins. A row is added when set up
+-- starts and only enabled once a first code has been checked.
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Time step of the last code used, so a code can't be used twice.
+    last_counter BIGINT NOT NULL DEFAULT 0,
+    created DATETIME NOT NULL,
+    CONSTRAINT user_totp_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create the recovery_codes table.
+-- Single use codes to log in with when the authenticator app is lost. Only a
+-- SHA-256 of each is kept, and a code is deleted once it is used.
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash BINARY(32) NOT NULL,
+    CONSTRAINT recovery_codes_uc_code_hash UNIQUE (user_id, code_hash),
+    CONSTRAINT recovery_codes_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create the login_attempts table.
+-- Every login is recorded, the failures are counted to slow down and then lock
+-- out password guessing. Also useful for an admin to look back over.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    -- As typed and lower cased, it may not belong to any account.
+    email VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    outcome ENUM('success', 'failure', 'blocked') NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_login_attempts_email ON login_attempts(email, created);
+
+CREATE INDEX idx_login_attempts_ip ON login_attempts(ip, created);
+
+-- Create the user_sessions table.
+-- One row per logged in browser or device, shown on the account sessions page.
+-- A session stores the id of its row and is logged out once the row is gone.
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    -- Where it was last used from, updated as it moves around.
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL,
+    last_seen DATETIME NOT NULL,
+    -- When the session itself expires, rows past it are cleared out on login.
+    expires DATETIME NOT NULL,
+    CONSTRAINT user_sessions_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_sessions_user ON user_sessions(user_id, expires);
+
 -- Create the sessions table.
 -- NOTE: Same as before, the `IF NOT EXISTS` clause was moved to the correct position.
 -- Using `BLOB` is fine for binary data, but `JSON` is another good option if the
@@ -0,0 +1,227 @@
+-- Brings a database made with an older setup.sql up to date, without losing
+-- what is in it. It checks for each change before making it, so it is safe to
+-- run more than once, and on a new database it does nothing. Run it as root
+-- (the web user can't change tables) with the mysql client, which knows
+-- DELIMITER:
+--
+--     mysql -u root -p < initdb/upgrade.sql
+--
+-- MySQL has no ADD COLUMN IF NOT EXISTS, so the checks are done in a stored
+-- procedure that is dropped again at the end. Changes are in the order they
+-- were made to setup.sql.
+
+USE snippetbox;
+
+DROP FUNCTION IF EXISTS upgrade_column_exists;
+DROP FUNCTION IF EXISTS upgrade_column_nullable;
+DROP FUNCTION IF EXISTS upgrade_index_exists;
+DROP FUNCTION IF EXISTS upgrade_short_id;
+DROP PROCEDURE IF EXISTS upgrade_schema;
+
+DELIMITER //
+
+CREATE FUNCTION upgrade_column_exists(tbl VARCHAR(64), col VARCHAR(64)) RETURNS BOOLEAN
+READS SQL DATA
+BEGIN
+    RETURN EXISTS (SELECT 1 FROM information_schema.COLUMNS
+        WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = tbl AND COLUMN_NAME = col);
+END//
+
+-- Checked before changing a column's NULL, so running again doesn't rebuild
+-- the table.
+CREATE FUNCTION upgrade_column_nullable(tbl VARCHAR(64), col VARCHAR(64)) RETURNS BOOLEAN
+READS SQL DATA
+BEGIN
+    RETURN EXISTS (SELECT 1 FROM information_schema.COLUMNS
+        WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = tbl AND COLUMN_NAME = col AND IS_NULLABLE = 'YES');
+END//
+
+-- Unique constraints count as indexes too.
+CREATE FUNCTION upgrade_index_exists(tbl VARCHAR(64), idx VARCHAR(64)) RETURNS BOOLEAN
+READS SQL DATA
+BEGIN
+    RETURN EXISTS (SELECT 1 FROM information_schema.STATISTICS
+        WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = tbl AND INDEX_NAME = idx);
+END//
+
+-- A random 10 char base62 ID, made the same way as newShortID in
+-- internal/models/shortid.go.
+CREATE FUNCTION upgrade_short_id() RETURNS CHAR(10) CHARACTER SET ascii
+NOT DETERMINISTIC NO SQL
+BEGIN
+    DECLARE alphabet CHAR(62) DEFAULT '0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz';
+    DECLARE id VARCHAR(10) CHARACTER SET ascii DEFAULT '';
+    DECLARE b INT;
+    WHILE LENGTH(id) < 10 DO
+        SET b = ORD(RANDOM_BYTES(1));
+        -- Bytes above 247 are thrown away so every char is equally likely.
+        IF b < 248 THEN
+            SET id = CONCAT(id, SUBSTRING(alphabet, 1 + b % 62, 1));
+        END IF;
+    END WHILE;
+    RETURN id;
+END//
+
+CREATE PROCEDURE upgrade_schema()
+BEGIN
+    -- Existing snippets are left without an owner, which is what anonymous
+    -- snippets look like.
+    IF NOT upgrade_column_exists('snippets', 'user_id') THEN
+        ALTER TABLE snippets ADD COLUMN user_id INTEGER AFTER expires;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM information_schema.TABLE_CONSTRAINTS
+            WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'snippets' AND CONSTRAINT_NAME = 'snippets_fk_user') THEN
+        ALTER TABLE snippets ADD CONSTRAINT snippets_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+    END IF;
+
+    -- Snippets from before revisions existed get their revision 1 the first
+    -- time they are edited.
+    CREATE TABLE IF NOT EXISTS snippet_revisions (
+        id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+        snippet_id INTEGER NOT NULL,
+        revision INTEGER NOT NULL,
+        title VARCHAR(100) NOT NULL,
+        content TEXT NOT NULL,
+        created DATETIME NOT NULL,
+        CONSTRAINT snippet_revisions_fk_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+        CONSTRAINT snippet_revisions_uc_revision UNIQUE (snippet_id, revision)
+    );
+
+    -- Short IDs are added empty, filled in and only then made required and
+    -- unique. Two rows drawing the same ID is all but impossible, but if it
+    -- happens one of them draws again.
+    IF NOT upgrade_column_exists('snippets', 'short_id') THEN
+        ALTER TABLE snippets ADD COLUMN short_id CHAR(10) CHARACTER SET ascii COLLATE ascii_bin NULL AFTER id;
+    END IF;
+    REPEAT
+        UPDATE snippets SET short_id = upgrade_short_id() WHERE short_id IS NULL;
+        UPDATE snippets s
+            JOIN (SELECT MAX(id) AS id FROM snippets GROUP BY short_id HAVING COUNT(*) > 1) dupes ON s.id = dupes.id
+            SET s.short_id = NULL;
+    UNTIL NOT EXISTS (SELECT 1 FROM snippets WHERE short_id IS NULL) END REPEAT;
+    IF upgrade_column_nullable('snippets', 'short_id') THEN
+        ALTER TABLE snippets MODIFY short_id CHAR(10) CHARACTER SET ascii COLLATE ascii_bin NOT NULL;
+    END IF;
+    IF NOT upgrade_index_exists('snippets', 'snippets_uc_short_id') THEN
+        ALTER TABLE snippets ADD CONSTRAINT snippets_uc_short_id UNIQUE (short_id);
+    END IF;
+
+    -- Everything was on the home page before, so existing snippets stay public.
+    IF NOT upgrade_column_exists('snippets', 'visibility') THEN
+        ALTER TABLE snippets ADD COLUMN visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'public' AFTER user_id;
+    END IF;
+
+    IF NOT upgrade_column_exists('snippets', 'burn_after_reading') THEN
+        ALTER TABLE snippets ADD COLUMN burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE AFTER visibility;
+    END IF;
+
+    IF NOT upgrade_column_exists('snippets', 'hashed_password') THEN
+        ALTER TABLE snippets ADD COLUMN hashed_password CHAR(60) NOT NULL DEFAULT '' AFTER burn_after_reading;
+    END IF;
+
+    -- NULL is now a snippet that never expires.
+    IF NOT upgrade_column_nullable('snippets', 'expires') THEN
+        ALTER TABLE snippets MODIFY expires DATETIME NULL;
+    END IF;
+
+    IF NOT upgrade_index_exists('snippets', 'idx_snippets_expires') THEN
+        CREATE INDEX idx_snippets_expires ON snippets(expires);
+    END IF;
+
+    IF NOT upgrade_column_exists('snippets', 'language') THEN
+        ALTER TABLE snippets ADD COLUMN language VARCHAR(32) NOT NULL DEFAULT '' AFTER hashed_password;
+    END IF;
+
+    -- Until a snippet is edited it was last changed when it was created.
+    IF NOT upgrade_column_exists('snippets', 'updated') THEN
+        ALTER TABLE snippets ADD COLUMN updated DATETIME NULL AFTER created;
+        UPDATE snippets SET updated = created;
+        ALTER TABLE snippets MODIFY updated DATETIME NOT NULL;
+    END IF;
+
+    CREATE TABLE IF NOT EXISTS api_tokens (
+        id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+        user_id INTEGER NOT NULL,
+        name VARCHAR(100) NOT NULL,
+        token_hash BINARY(32) NOT NULL,
+        scopes SET('read', 'write', 'delete') NOT NULL,
+        created DATETIME NOT NULL,
+        last_used DATETIME,
+        expires DATETIME,
+        CONSTRAINT api_tokens_uc_token_hash UNIQUE (token_hash),
+        CONSTRAINT api_tokens_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+
+    -- CREATE TABLE IF NOT EXISTS leaves a table that is already there alone,
+    -- so its indexes are checked for separately.
+    CREATE TABLE IF NOT EXISTS login_attempts (
+        id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+        email VARCHAR(255) NOT NULL,
+        ip VARCHAR(45) NOT NULL,
+        outcome ENUM('success', 'failure', 'blocked') NOT NULL,
+        created DATETIME NOT NULL
+    );
+    IF NOT upgrade_index_exists('login_attempts', 'idx_login_attempts_email') THEN
+        CREATE INDEX idx_login_attempts_email ON login_attempts(email, created);
+    END IF;
+    IF NOT upgrade_index_exists('login_attempts', 'idx_login_attempts_ip') THEN
+        CREATE INDEX idx_login_attempts_ip ON login_attempts(ip, created);
+    END IF;
+
+    CREATE TABLE IF NOT EXISTS password_resets (
+        id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+        user_id INTEGER NOT NULL,
+        token_hash BINARY(32) NOT NULL,
+        created DATETIME NOT NULL,
+        expires DATETIME NOT NULL,
+        CONSTRAINT password_resets_uc_token_hash UNIQUE (token_hash),
+        CONSTRAINT password_resets_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+
+    -- Accounts from before email verification are let off it, they would
+    -- otherwise lose the ability to make snippets.
+    IF NOT upgrade_column_exists('users', 'email_verified') THEN
+        ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+        UPDATE users SET email_verified = TRUE;
+    END IF;
+
+    CREATE TABLE IF NOT EXISTS user_totp (
+        user_id INTEGER NOT NULL PRIMARY KEY,
+        secret VARCHAR(64) NOT NULL,
+        enabled BOOLEAN NOT NULL DEFAULT FALSE,
+        last_counter BIGINT NOT NULL DEFAULT 0,
+        created DATETIME NOT NULL,
+        CONSTRAINT user_totp_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+    CREATE TABLE IF NOT EXISTS recovery_codes (
+        id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+        user_id INTEGER NOT NULL,
+        code_hash BINARY(32) NOT NULL,
+        CONSTRAINT recovery_codes_uc_code_hash UNIQUE (user_id, code_hash),
+        CONSTRAINT recovery_codes_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+
+    CREATE TABLE IF NOT EXISTS user_sessions (
+        id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+        user_id INTEGER NOT NULL,
+        ip VARCHAR(45) NOT NULL,
+        user_agent VARCHAR(255) NOT NULL,
+        created DATETIME NOT NULL,
+        last_seen DATETIME NOT NULL,
+        expires DATETIME NOT NULL,
+        CONSTRAINT user_sessions_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+    IF NOT upgrade_index_exists('user_sessions', 'idx_user_sessions_user') THEN
+        CREATE INDEX idx_user_sessions_user ON user_sessions(user_id, expires);
+    END IF;
+END//
+
+DELIMITER ;
+
+CALL upgrade_schema();
+
+DROP PROCEDURE upgrade_schema;
+DROP FUNCTION upgrade_short_id;
+DROP FUNCTION upgrade_index_exists;
+DROP FUNCTION upgrade_column_nullable;
+DROP FUNCTION upgrade_column_exists;
@@ -0,0 +1,361 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kind says what happened to a line between the old and new text
+type Kind int
+
+const (
+	Equal Kind = iota
+	Insert
+	Delete
+)
+
+// String names the kind, handy as a CSS class
+func (k Kind) String() string {
+	switch k {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// Symbol returns the prefix used for the line in unified diff output
+func (k Kind) Symbol() string {
+	switch k {
+	case Insert:
+		return "+"
+	case Delete:
+		return "-"
+	default:
+		return " "
+	}
+}
+
+// Line is a single line of diff output
+type Line struct {
+	Kind Kind
+	Text string
+}
+
+// Hunk is a group of changed lines plus the unchanged context around them
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Header returns the @@ line for the hunk, same format as diff -u
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", span(h.OldStart, h.OldLines), span(h.NewStart, h.NewLines))
+}
+
+func span(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// Unified compares old and new line by line and returns the changes as hunks,
+// each with up to context unchanged lines either side. Identical texts give no
+// hunks. ErrTooDifferent if they are too big or too far apart to compare
+func Unified(old, new string, context int) ([]Hunk, error) {
+	lines, err := Lines(splitLines(old), splitLines(new))
+	if err != nil {
+		return nil, err
+	}
+
+	hunks := []Hunk{}
+	var h *Hunk
+	oldNum, newNum := 1, 1
+	//index of the last changed line, used to decide when a hunk has enough trailing context
+	lastChange := -1
+
+	for i, l := range lines {
+		if l.Kind != Equal {
+			if h == nil {
+				//start a new hunk, pulling in the unchanged lines before this one
+				start := max(i-context, lastChange+1, 0)
+				h = &Hunk{
+					OldStart: oldNum - (i - start),
+					NewStart: newNum - (i - start),
+				}
+				for _, c := range lines[start:i] {
+					h.Lines = append(h.Lines, c)
+					h.OldLines++
+					h.NewLines++
+				}
+			}
+			lastChange = i
+		}
+
+		if h != nil {
+			if l.Kind == Equal && i-lastChange > context && !changedWithin(lines, i, lastChange+2*context+1) {
+				//enough context after the last change and the next one is too far off
+				//to share it, close the hunk
+				hunks = append(hunks, *h)
+				h = nil
+			} else {
+				h.Lines = append(h.Lines, l)
+				if l.Kind != Insert {
+					h.OldLines++
+				}
+				if l.Kind != Delete {
+					h.NewLines++
+				}
+			}
+		}
+
+		if l.Kind != Insert {
+			oldNum++
+		}
+		if l.Kind != Delete {
+			newNum++
+		}
+	}
+	if h != nil {
+		hunks = append(hunks, *h)
+	}
+
+	//diff -u numbers an empty side from 0 rather than 1
+	for i := range hunks {
+		if hunks[i].OldLines == 0 {
+			hunks[i].OldStart--
+		}
+		if hunks[i].NewLines == 0 {
+			hunks[i].NewStart--
+		}
+	}
+	return hunks, nil
+}
+
+// changedWithin reports whether any line from index i up to and including end is a change
+func changedWithin(lines []Line, i, end int) bool {
+	for ; i <= end && i < len(lines); i++ {
+		if lines[i].Kind != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Limits on what Lines will compare. Each is well past any real edit, but
+// stops two large unrelated revisions from costing much time
+const (
+	MaxLines = 20000 //both texts together, after common lines at the start and end
+	MaxEdits = 4000  //lines inserted plus deleted
+)
+
+// ErrTooDifferent is returned when the texts are over MaxLines or MaxEdits
+var ErrTooDifferent = errors.New("diff: revisions differ too much to diff")
+
+// Lines returns the shortest edit script turning a into b, one entry per line.
+// This is the linear space version of Myers' O(ND) algorithm: it finds the
+// middle of the edit path, then splits the texts there and does each half, so
+// memory stays proportional to the input and not the number of edits
+func Lines(a, b []string) ([]Line, error) {
+	//lines are swapped for numbers so comparing them is cheap
+	ids := map[string]int{}
+	intern := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, l := range lines {
+			id, ok := ids[l]
+			if !ok {
+				id = len(ids)
+				ids[l] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+
+	d := &differ{a: a, b: b, ai: intern(a), bi: intern(b), lines: make([]Line, 0, max(len(a), len(b)))}
+	prefix := d.commonPrefix(0, len(a), 0, len(b))
+	suffix := d.commonSuffix(prefix, len(a), prefix, len(b))
+	if len(a)+len(b)-2*(prefix+suffix) > MaxLines {
+		return nil, ErrTooDifferent
+	}
+
+	err := d.compare(0, len(a), 0, len(b))
+	if err != nil {
+		return nil, err
+	}
+	return d.lines, nil
+}
+
+type differ struct {
+	a, b   []string
+	ai, bi []int
+	lines  []Line
+}
+
+// compare appends the edits turning a[aLo:aHi] into b[bLo:bHi]
+func (d *differ) compare(aLo, aHi, bLo, bHi int) error {
+	prefix := d.commonPrefix(aLo, aHi, bLo, bHi)
+	for i := range prefix {
+		d.lines = append(d.lines, Line{Kind: Equal, Text: d.a[aLo+i]})
+	}
+	aLo += prefix
+	bLo += prefix
+	suffix := d.commonSuffix(aLo, aHi, bLo, bHi)
+	aHi -= suffix
+	bHi -= suffix
+
+	switch {
+	case aLo == aHi:
+		for _, t := range d.b[bLo:bHi] {
+			d.lines = append(d.lines, Line{Kind: Insert, Text: t})
+		}
+	case bLo == bHi:
+		for _, t := range d.a[aLo:aHi] {
+			d.lines = append(d.lines, Line{Kind: Delete, Text: t})
+		}
+	default:
+		//both sides start and end differently here, so there are at least two
+		//edits and both halves are smaller than the whole
+		x, y, err := d.middle(aLo, aHi, bLo, bHi)
+		if err != nil {
+			return err
+		}
+		err = d.compare(aLo, x, bLo, y)
+		if err != nil {
+			return err
+		}
+		err = d.compare(x, aHi, y, bHi)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range suffix {
+		d.lines = append(d.lines, Line{Kind: Equal, Text: d.a[aHi+i]})
+	}
+	return nil
+}
+
+func (d *differ) commonPrefix(aLo, aHi, bLo, bHi int) int {
+	n := 0
+	for aLo+n < aHi && bLo+n < bHi && d.ai[aLo+n] == d.bi[bLo+n] {
+		n++
+	}
+	return n
+}
+
+func (d *differ) commonSuffix(aLo, aHi, bLo, bHi int) int {
+	n := 0
+	for aHi-n > aLo && bHi-n > bLo && d.ai[aHi-n-1] == d.bi[bHi-n-1] {
+		n++
+	}
+	return n
+}
+
+// middle runs the search forwards from the start and backwards from the end at
+// the same time, and returns the point where the two paths meet. That point
+// is on a shortest edit path, so the halves either side can be done on their
+// own. With nothing in common it is the end of a and start of b, all of a is
+// deleted then all of b inserted. ErrTooDifferent if the paths haven't met
+// within MaxEdits
+func (d *differ) middle(aLo, aHi, bLo, bHi int) (int, int, error) {
+	a, b := d.ai[aLo:aHi], d.bi[bLo:bHi]
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	//v holds the furthest x reached on each diagonal k, forwards from the
+	//start and backwards from the end
+	vf := make([]int, 2*maxD+2)
+	vb := make([]int, 2*maxD+2)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[offset+1], vb[offset+1] = 0, 0
+	delta := n - m
+	//with an odd delta the forward path is the one to hit the backward one
+	front := delta%2 != 0
+	//diagonals that have run off the edge aren't searched again
+	kfStart, kfEnd, kbStart, kbEnd := 0, 0, 0, 0
+
+	for e := 0; e < maxD; e++ {
+		if 2*e > MaxEdits {
+			return 0, 0, ErrTooDifferent
+		}
+		for k := -e + kfStart; k <= e-kfEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -e || (k != e && vf[i-1] < vf[i+1]) {
+				x = vf[i+1]
+			} else {
+				x = vf[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[i] = x
+			switch {
+			case x > n:
+				kfEnd += 2
+			case y > m:
+				kfStart += 2
+			case front:
+				j := offset + delta - k
+				if j >= 0 && j < len(vb) && vb[j] != -1 && x >= n-vb[j] {
+					return aLo + x, bLo + y, nil
+				}
+			}
+		}
+
+		for k := -e + kbStart; k <= e-kbEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -e || (k != e && vb[i-1] < vb[i+1]) {
+				x = vb[i+1]
+			} else {
+				x = vb[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			vb[i] = x
+			switch {
+			case x > n:
+				kbEnd += 2
+			case y > m:
+				kbStart += 2
+			case !front:
+				j := offset + delta - k
+				if j >= 0 && j < len(vf) && vf[j] != -1 {
+					fx := vf[j]
+					fy := offset + fx - j
+					if fx >= n-x {
+						return aLo + fx, bLo + fy, nil
+					}
+				}
+			}
+		}
+	}
+	//the paths only fail to meet when there are no lines in common
+	return aHi, bLo, nil
+}
+
+// splitLines breaks text into lines, ignoring windows line endings and a trailing newline
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "\n")
+}
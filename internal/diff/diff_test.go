@@ -0,0 +1,168 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"snippetbox/internal/assert"
+	"strings"
+	"testing"
+)
+
+// format renders hunks the way diff -u would, minus the file headers
+func format(hunks []Hunk) string {
+	var sb strings.Builder
+	for _, h := range hunks {
+		sb.WriteString(h.Header() + "\n")
+		for _, l := range h.Lines {
+			sb.WriteString(l.Kind.Symbol() + l.Text + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func TestUnified(t *testing.T) {
+	// Expected output was checked against GNU diff -u with the same inputs.
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{
+			name: "Identical",
+			old:  "a\nb\nc",
+			new:  "a\nb\nc",
+			want: "",
+		},
+		{
+			name: "Added to empty",
+			old:  "",
+			new:  "a\nb",
+			want: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "Everything removed",
+			old:  "a\nb",
+			new:  "",
+			want: "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "Delete and append",
+			old:  "1\n2\n3",
+			new:  "1\n3\n4",
+			want: "@@ -1,3 +1,3 @@\n 1\n-2\n 3\n+4\n",
+		},
+		{
+			name: "Windows line endings",
+			old:  "1\r\n2\r\n",
+			new:  "1\n2\n",
+			want: "",
+		},
+		{
+			name: "Changes close together share a hunk",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10",
+			new:  "1\nX\n3\n4\n5\n6\n7\n8\nY\n10",
+			want: "@@ -1,10 +1,10 @@\n 1\n-2\n+X\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n+Y\n 10\n",
+		},
+		{
+			name: "Changes far apart get their own hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11",
+			new:  "1\nX\n3\n4\n5\n6\n7\n8\n9\nY\n11",
+			want: "@@ -1,5 +1,5 @@\n 1\n-2\n+X\n 3\n 4\n 5\n@@ -7,5 +7,5 @@\n 7\n 8\n 9\n-10\n+Y\n 11\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks, err := Unified(tt.old, tt.new, 3)
+			assert.NilError(t, err)
+			assert.Equal(t, format(hunks), tt.want)
+		})
+	}
+}
+
+// lcs is the length of the longest common subsequence, worked out the slow
+// way to check Lines finds a shortest edit script
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
+
+func TestLinesShortest(t *testing.T) {
+	//small alphabets so there is plenty in common to line up
+	rng := rand.New(rand.NewPCG(1, 2))
+	random := func() []string {
+		lines := make([]string, rng.IntN(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.IntN(4)))
+		}
+		return lines
+	}
+
+	for i := range 500 {
+		a, b := random(), random()
+		lines, err := Lines(a, b)
+		assert.NilError(t, err)
+
+		//the script has to turn a into b
+		var gotA, gotB []string
+		edits := 0
+		for _, l := range lines {
+			if l.Kind != Insert {
+				gotA = append(gotA, l.Text)
+			}
+			if l.Kind != Delete {
+				gotB = append(gotB, l.Text)
+			}
+			if l.Kind != Equal {
+				edits++
+			}
+		}
+		assert.Equal(t, strings.Join(gotA, ""), strings.Join(a, ""))
+		assert.Equal(t, strings.Join(gotB, ""), strings.Join(b, ""))
+		if edits != len(a)+len(b)-2*lcs(a, b) {
+			t.Fatalf("case %d: %d edits for %q -> %q, want %d", i, edits, a, b, len(a)+len(b)-2*lcs(a, b))
+		}
+	}
+}
+
+func TestUnifiedLarge(t *testing.T) {
+	numbered := func(prefix string, n int) string {
+		var sb strings.Builder
+		for i := range n {
+			fmt.Fprintf(&sb, "%s %d\n", prefix, i)
+		}
+		return sb.String()
+	}
+
+	t.Run("Unrelated", func(t *testing.T) {
+		_, err := Unified(numbered("old", 3000), numbered("new", 3000), 3)
+		assert.Equal(t, err, ErrTooDifferent)
+	})
+
+	t.Run("Too many lines", func(t *testing.T) {
+		_, err := Unified(numbered("old", MaxLines), numbered("new", 10), 3)
+		assert.Equal(t, err, ErrTooDifferent)
+	})
+
+	t.Run("Big but similar", func(t *testing.T) {
+		old := numbered("line", 30000)
+		new := strings.Replace(old, "line 15000\n", "changed\n", 1)
+		hunks, err := Unified(old, new, 3)
+		assert.NilError(t, err)
+		assert.Equal(t, len(hunks), 1)
+		assert.Equal(t, hunks[0].Header(), "@@ -14998,7 +14998,7 @@")
+	})
+}
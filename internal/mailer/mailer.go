@@ -0,0 +1,94 @@
+// Package mailer sends the emails the site needs, like password reset links.
+// SMTP sends them for real, Log just writes them out so development and tests
+// don't need a mail server
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer sends a plain text email
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+var errHeaderInjection = errors.New("mailer: newline in header")
+
+// SMTP sends mail through an SMTP server, using STARTTLS when the server offers it
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string //no AUTH if empty
+	Password string
+	From     string //e.g. "Snippetbox <no-reply@example.com>"
+}
+
+func (m *SMTP) Send(to, subject, body string) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("mailer: from address: %w", err)
+	}
+	msg, err := message(m.From, to, subject, body, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	return smtp.SendMail(addr, auth, from.Address, []string{to}, msg)
+}
+
+// Log writes each email to Out instead of sending it, e.g. to stdout while
+// developing so reset links can be copied from the terminal
+type Log struct {
+	mu  sync.Mutex
+	Out io.Writer
+}
+
+func (m *Log) Send(to, subject, body string) error {
+	msg, err := message("snippetbox", to, subject, body, time.Now())
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err = fmt.Fprintf(m.Out, "%s\n\n", msg)
+	return err
+}
+
+// message builds an RFC 5322 message. The subject is encoded in case it isn't
+// plain ASCII, and the body is sent as is with CRLF line endings
+func message(from, to, subject, body string, date time.Time) ([]byte, error) {
+	for _, v := range []string{from, to, subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, errHeaderInjection
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"bytes"
+	"snippetbox/internal/assert"
+	"testing"
+	"time"
+)
+
+func TestMessage(t *testing.T) {
+	date := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+	msg, err := message("Snippetbox <no-reply@example.com>", "alice@example.com", "Reset your password", "Hi\nClick here", date)
+	assert.NilError(t, err)
+
+	want := "From: Snippetbox <no-reply@example.com>\r\n" +
+		"To: alice@example.com\r\n" +
+		"Subject: Reset your password\r\n" +
+		"Date: Sun, 17 Mar 2024 10:15:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"Hi\r\nClick here"
+	assert.Equal(t, string(msg), want)
+}
+
+func TestMessageHeaderInjection(t *testing.T) {
+	tests := []struct {
+		name    string
+		to      string
+		subject string
+	}{
+		{name: "To", to: "alice@example.com\r\nBcc: bob@example.com", subject: "Hi"},
+		{name: "Subject", to: "alice@example.com", subject: "Hi\nBcc: bob@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := message("no-reply@example.com", tt.to, tt.subject, "body", time.Now())
+			assert.Equal(t, err, errHeaderInjection)
+		})
+	}
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	m := &Log{Out: &buf}
+
+	err := m.Send("alice@example.com", "Héllo", "Hi Alice")
+	assert.NilError(t, err)
+	assert.StringContains(t, buf.String(), "To: alice@example.com\r\n")
+	assert.StringContains(t, buf.String(), "Subject: =?utf-8?q?H=C3=A9llo?=\r\n")
+	assert.StringContains(t, buf.String(), "\r\n\r\nHi Alice")
+}
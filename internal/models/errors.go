@@ -9,4 +9,7 @@ var (
 
 	// email must be uniq, its a constraint on the mysql table column
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	// a two-factor code that has already been used to log in
+	ErrCodeUsed = errors.New("models: code already used")
 )
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// What happened to a login attempt
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure" //wrong email or password
+	LoginBlocked = "blocked" //turned away by the lockout before the password was checked
+)
+
+// LoginAttemptModel records every try at logging in, for the lockout to count
+// and so an admin can look back over them
+type LoginAttemptModel struct {
+	DB *sql.DB
+}
+
+type LoginAttemptModelInterface interface {
+	Insert(email, ip, outcome string) error
+	EmailFailures(email string, since time.Time) (int, time.Time, error)
+	IPFailures(ip string, since time.Time) (int, time.Time, error)
+}
+
+// emails are compared case insensitively by the users table, so attempts are
+// stored lower case to count "Alice@" and "alice@" together
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (m *LoginAttemptModel) Insert(email, ip, outcome string) error {
+	stmt := `INSERT INTO login_attempts (email, ip, outcome, created)
+    VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, normalizeEmail(email), ip, outcome)
+	return err
+}
+
+// EmailFailures counts the failed logins to an account since the later of
+// since and its last successful login, and returns when the latest one was
+func (m *LoginAttemptModel) EmailFailures(email string, since time.Time) (int, time.Time, error) {
+	stmt := `SELECT COUNT(*), MAX(created) FROM login_attempts
+    WHERE email = ? AND outcome = 'failure' AND created > ? AND created > COALESCE(
+        (SELECT MAX(created) FROM login_attempts WHERE email = ? AND outcome = 'success'), '1000-01-01')`
+
+	email = normalizeEmail(email)
+	return m.failures(stmt, email, since, email)
+}
+
+// IPFailures counts the failed logins from an IP since since. Unlike
+// EmailFailures a success doesn't reset the count, or an attacker could clear
+// it by logging in to an account of their own
+func (m *LoginAttemptModel) IPFailures(ip string, since time.Time) (int, time.Time, error) {
+	stmt := `SELECT COUNT(*), MAX(created) FROM login_attempts
+    WHERE ip = ? AND outcome = 'failure' AND created > ?`
+
+	return m.failures(stmt, ip, since)
+}
+
+func (m *LoginAttemptModel) failures(stmt string, args ...any) (int, time.Time, error) {
+	var count int
+	var last sql.NullTime
+	err := m.DB.QueryRow(stmt, args...).Scan(&count, &last)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return count, last.Time, nil
+}
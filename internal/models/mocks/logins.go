@@ -0,0 +1,30 @@
+package mocks
+
+import "time"
+
+type LoginAttemptModel struct{}
+
+func (m *LoginAttemptModel) Insert(email, ip, outcome string) error {
+	return nil
+}
+
+// slow@example.com has failed just enough times to be slowed down and
+// locked@example.com enough to be locked out, both just now
+func (m *LoginAttemptModel) EmailFailures(email string, since time.Time) (int, time.Time, error) {
+	switch email {
+	case "slow@example.com":
+		return 5, time.Now(), nil
+	case "locked@example.com":
+		return 10, time.Now(), nil
+	default:
+		return 0, time.Time{}, nil
+	}
+}
+
+// 192.0.2.66 has been trying a lot of accounts
+func (m *LoginAttemptModel) IPFailures(ip string, since time.Time) (int, time.Time, error) {
+	if ip == "192.0.2.66" {
+		return 100, time.Now(), nil
+	}
+	return 0, time.Time{}, nil
+}
@@ -0,0 +1,32 @@
+package mocks
+
+import (
+	"snippetbox/internal/models"
+	"time"
+)
+
+// MockResetToken is the token Insert hands out, and the only one Check and Reset accept
+const MockResetToken = "Pr7kQ2wX9mZ4vB8nC1dF6gH3jK5lT0yU2iO4pA6s"
+
+type PasswordResetModel struct{}
+
+func (m *PasswordResetModel) Insert(email string, ttl time.Duration) (string, error) {
+	if email == "alice@example.com" {
+		return MockResetToken, nil
+	}
+	return "", models.ErrNoRecord
+}
+
+func (m *PasswordResetModel) Check(plaintext string) error {
+	if plaintext == MockResetToken {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *PasswordResetModel) Reset(plaintext, password string) (int, error) {
+	if plaintext == MockResetToken {
+		return 1, nil
+	}
+	return 0, models.ErrNoRecord
+}
@@ -0,0 +1,88 @@
+package mocks
+
+import (
+	"snippetbox/internal/models"
+	"time"
+)
+
+// MockRevokedSessionID is a session of Alice's that has been signed out from
+// somewhere else, Touch turns it away
+const MockRevokedSessionID = 3
+
+// Logins get sessions 1, 2 and so on, and Alice is listed as logged in on a
+// second device as session 2. Sessions signed out by the Delete methods are
+// remembered so Touch turns them away
+type UserSessionModel struct {
+	users     map[int]int //session ID to user ID, of the logins so far
+	signedOut map[int]bool
+}
+
+func (m *UserSessionModel) Insert(userID int, ip, userAgent string, expires time.Time) (int, error) {
+	if m.users == nil {
+		m.users = make(map[int]int)
+	}
+	id := len(m.users) + 1
+	m.users[id] = userID
+	return id, nil
+}
+
+func (m *UserSessionModel) Touch(id, userID int, ip string) (bool, error) {
+	if id == 0 || id == MockRevokedSessionID || m.signedOut[id] {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (m *UserSessionModel) ListByUser(userID int) ([]*models.UserSession, error) {
+	if userID != mockUser.ID {
+		return []*models.UserSession{}, nil
+	}
+	return []*models.UserSession{
+		{
+			ID:        1,
+			UserID:    userID,
+			IP:        "127.0.0.1",
+			UserAgent: "Go-http-client/1.1",
+			Created:   time.Now(),
+			LastSeen:  time.Now(),
+			Expires:   time.Now().Add(48 * time.Hour),
+		},
+		{
+			ID:        2,
+			UserID:    userID,
+			IP:        "203.0.113.9",
+			UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:128.0) Gecko/20100101 Firefox/128.0",
+			Created:   time.Now().Add(-24 * time.Hour),
+			LastSeen:  time.Now().Add(-time.Hour),
+			Expires:   time.Now().Add(24 * time.Hour),
+		},
+	}, nil
+}
+
+func (m *UserSessionModel) Delete(id, userID int) error {
+	if userID == mockUser.ID && (id == 1 || id == 2) {
+		m.signOut(id)
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *UserSessionModel) DeleteOthers(userID, keepID int) error {
+	for id, user := range m.users {
+		if user == userID && id != keepID {
+			m.signOut(id)
+		}
+	}
+	return nil
+}
+
+func (m *UserSessionModel) DeleteByUser(userID int) error {
+	return m.DeleteOthers(userID, 0)
+}
+
+func (m *UserSessionModel) signOut(id int) {
+	if m.signedOut == nil {
+		m.signedOut = make(map[int]bool)
+	}
+	m.signedOut[id] = true
+}
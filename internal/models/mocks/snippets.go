@@ -6,23 +6,125 @@ import (
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:         1,
+	ShortID:    "Xk3pQ9aZ1b",
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	Expires:    time.Now(),
+	UserID:     1,
+	Visibility: models.VisibilityPublic,
+	Language:   "plaintext",
 }
 
-type SnippetModel struct{}
+// owned by a user other than the mocked logged in user
+var mockForeignSnippet = &models.Snippet{
+	ID:         3,
+	ShortID:    "Rt7mW2cV8n",
+	Title:      "Over the wintry forest",
+	Content:    "Over the wintry forest, winds howl in rage...",
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	Expires:    time.Now(),
+	UserID:     2,
+	Visibility: models.VisibilityPublic,
+}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	return 2, nil
+// private, so only user 2 is allowed to see it
+var mockPrivateSnippet = &models.Snippet{
+	ID:         4,
+	ShortID:    "Pv5tH8kL2q",
+	Title:      "First autumn morning",
+	Content:    "First autumn morning, the mirror I stare into...",
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	Expires:    time.Now(),
+	UserID:     2,
+	Visibility: models.VisibilityPrivate,
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	switch id {
-	case 1:
+// a burn after reading snippet
+var mockBurnSnippet = &models.Snippet{
+	ID:               5,
+	ShortID:          "Bn9rT4uY6w",
+	Title:            "The light of a candle",
+	Content:          "The light of a candle is transferred to another candle...",
+	Created:          time.Now(),
+	Updated:          time.Now(),
+	Expires:          time.Now(),
+	UserID:           2,
+	Visibility:       models.VisibilityUnlisted,
+	BurnAfterReading: true,
+}
+
+// needs the password "open sesame" to read
+var mockLockedSnippet = &models.Snippet{
+	ID:             6,
+	ShortID:        "Lk2dF7gJ3s",
+	Title:          "A world of dew",
+	Content:        "A world of dew, and within every dewdrop...",
+	Created:        time.Now(),
+	Updated:        time.Now(),
+	Expires:        time.Now(),
+	UserID:         2,
+	Visibility:     models.VisibilityUnlisted,
+	HashedPassword: []byte("hash is never checked, see Unlock below"),
+}
+
+var mockRevisions = []*models.Revision{
+	{
+		SnippetID: 1,
+		Revision:  2,
+		Title:     "An old silent pond",
+		Content:   "An old silent pond...",
+		Created:   time.Now(),
+	},
+	{
+		SnippetID: 1,
+		Revision:  1,
+		Title:     "An old silent pond",
+		Content:   "An old noisy pond...",
+		Created:   time.Now(),
+	},
+}
+
+type SnippetModel struct {
+	Expired int //how many expired snippets DeleteExpired has left to delete
+}
+
+func (m *SnippetModel) Insert(snippet *models.Snippet, password string) (string, error) {
+	return "Nw4sE6yH0j", nil
+}
+
+func (m *SnippetModel) Get(shortID string) (*models.Snippet, error) {
+	switch shortID {
+	case mockSnippet.ShortID:
 		return mockSnippet, nil
+	case mockForeignSnippet.ShortID:
+		return mockForeignSnippet, nil
+	case mockPrivateSnippet.ShortID:
+		return mockPrivateSnippet, nil
+	case mockBurnSnippet.ShortID:
+		return mockBurnSnippet, nil
+	case mockLockedSnippet.ShortID:
+		return mockLockedSnippet, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Unlock(shortID string, password string) error {
+	if shortID == mockLockedSnippet.ShortID && password == "open sesame" {
+		return nil
+	}
+	return models.ErrInvalidCredentials
+}
+
+func (m *SnippetModel) Burn(shortID string) (*models.Snippet, error) {
+	switch shortID {
+	case mockBurnSnippet.ShortID:
+		return mockBurnSnippet, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
@@ -31,3 +133,64 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	return []*models.Snippet{mockSnippet}, nil
 }
+
+func (m *SnippetModel) ListByUser(userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) Update(id int, title string, content string) error {
+	return nil
+}
+
+func (m *SnippetModel) UpdateExpiry(id int, expires time.Time) error {
+	return nil
+}
+
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	deleted := min(limit, m.Expired)
+	m.Expired -= deleted
+	return deleted, nil
+}
+
+func (m *SnippetModel) DeleteByUser(userID int) (int, error) {
+	if userID == 1 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m *SnippetModel) AnonymizeByUser(userID int) (int, error) {
+	return m.DeleteByUser(userID)
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	switch id {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	switch snippetID {
+	case 1:
+		return mockRevisions, nil
+	default:
+		return []*models.Revision{}, nil
+	}
+}
+
+func (m *SnippetModel) GetRevision(snippetID int, revision int) (*models.Revision, error) {
+	for _, r := range mockRevisions {
+		if r.SnippetID == snippetID && r.Revision == revision {
+			return r, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
@@ -0,0 +1,81 @@
+package mocks
+
+import (
+	"snippetbox/internal/models"
+	"time"
+)
+
+// plaintext tokens the mock accepts, the first two belong to the mocked user
+// with ID 1 and MockUnverifiedToken to the one who hasn't verified their email
+const (
+	MockToken           = "sb_Ab12Cd34Ef56Gh78Ij90Kl12Mn34Op56Qr78St90"
+	MockReadOnlyToken   = "sb_Zy98Xw76Vu54Ts32Rq10Po98Nm76Lk54Ji32Hg10"
+	MockUnverifiedToken = "sb_Qw12Er34Ty56Ui78Op90As12Df34Gh56Jk78Lz90"
+)
+
+var mockTokens = map[string]*models.Token{
+	MockToken: {
+		ID:      1,
+		UserID:  1,
+		Name:    "laptop",
+		Scopes:  []string{models.ScopeRead, models.ScopeWrite, models.ScopeDelete},
+		Created: time.Now(),
+	},
+	MockReadOnlyToken: {
+		ID:      2,
+		UserID:  1,
+		Name:    "ci",
+		Scopes:  []string{models.ScopeRead},
+		Created: time.Now(),
+		Expires: time.Now().Add(24 * time.Hour),
+	},
+	MockUnverifiedToken: {
+		ID:      3,
+		UserID:  3,
+		Name:    "desktop",
+		Scopes:  []string{models.ScopeRead, models.ScopeWrite},
+		Created: time.Now(),
+	},
+}
+
+// Tokens of users passed to DeleteByUser are remembered so Authenticate turns
+// them away
+type TokenModel struct {
+	revoked map[int]bool
+}
+
+func (m *TokenModel) Insert(userID int, name string, scopes []string, expires time.Time) (string, error) {
+	return "sb_Nw4sE6yH0jNw4sE6yH0jNw4sE6yH0jNw4sE6yH0j", nil
+}
+
+func (m *TokenModel) Authenticate(plaintext string) (*models.Token, error) {
+	t, ok := mockTokens[plaintext]
+	if !ok || m.revoked[t.UserID] {
+		return nil, models.ErrInvalidCredentials
+	}
+	return t, nil
+}
+
+func (m *TokenModel) ListByUser(userID int) ([]*models.Token, error) {
+	switch userID {
+	case 1:
+		return []*models.Token{mockTokens[MockReadOnlyToken], mockTokens[MockToken]}, nil
+	default:
+		return []*models.Token{}, nil
+	}
+}
+
+func (m *TokenModel) Delete(id int, userID int) error {
+	if userID == 1 && (id == 1 || id == 2) {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *TokenModel) DeleteByUser(userID int) error {
+	if m.revoked == nil {
+		m.revoked = make(map[int]bool)
+	}
+	m.revoked[userID] = true
+	return nil
+}
@@ -0,0 +1,69 @@
+package mocks
+
+import (
+	"snippetbox/internal/models"
+	"strings"
+	"time"
+)
+
+// MockTOTPSecret is the secret of both mocked set ups, codes for it can be
+// made with totp.Code
+const MockTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+// MockRecoveryCode is the only recovery code UseRecoveryCode accepts
+const MockRecoveryCode = "abcd-efgh-ijkl-mnop"
+
+// Alice has started setting up two-factor logins but not finished, Dave has
+// it turned on
+type TwoFactorModel struct{}
+
+func (m *TwoFactorModel) Get(userID int) (*models.TwoFactor, error) {
+	switch userID {
+	case mockUser.ID:
+		return &models.TwoFactor{UserID: userID, Secret: MockTOTPSecret, Created: time.Now()}, nil
+	case mockTwoFactorUser.ID:
+		return &models.TwoFactor{UserID: userID, Secret: MockTOTPSecret, Enabled: true, Created: time.Now()}, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *TwoFactorModel) Begin(userID int, secret string) error {
+	return nil
+}
+
+func (m *TwoFactorModel) Enable(userID int, counter int64) ([]string, error) {
+	if userID != mockUser.ID {
+		return nil, models.ErrNoRecord
+	}
+	codes := make([]string, 10)
+	for i := range codes {
+		codes[i] = MockRecoveryCode
+	}
+	return codes, nil
+}
+
+func (m *TwoFactorModel) Disable(userID int) error {
+	return nil
+}
+
+func (m *TwoFactorModel) UseCounter(userID int, counter int64) error {
+	if userID != mockTwoFactorUser.ID {
+		return models.ErrCodeUsed
+	}
+	return nil
+}
+
+func (m *TwoFactorModel) UseRecoveryCode(userID int, code string) error {
+	if userID == mockTwoFactorUser.ID && strings.EqualFold(code, MockRecoveryCode) {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *TwoFactorModel) RecoveryCodesLeft(userID int) (int, error) {
+	if userID == mockTwoFactorUser.ID {
+		return 9, nil
+	}
+	return 0, nil
+}
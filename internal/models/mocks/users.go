@@ -1,32 +1,113 @@
 package mocks
 
-import "snippetbox/internal/models"
+import (
+	"snippetbox/internal/models"
+	"time"
+)
 
 // more tests for the test gods
 // testing user interacts with the DB
 type UserModel struct{}
 
-func (m *UserModel) Insert(name, email, password string) error {
+var mockUser = &models.User{
+	ID:            1,
+	Name:          "Alice",
+	Email:         "alice@example.com",
+	Created:       time.Now(),
+	EmailVerified: true,
+}
+
+// has signed up but not followed the link in the verification email yet
+var mockUnverifiedUser = &models.User{
+	ID:      3,
+	Name:    "Carol",
+	Email:   "carol@example.com",
+	Created: time.Now(),
+}
+
+// has two-factor logins turned on, see TwoFactorModel
+var mockTwoFactorUser = &models.User{
+	ID:            5,
+	Name:          "Dave",
+	Email:         "dave@example.com",
+	Created:       time.Now(),
+	EmailVerified: true,
+}
+
+func (m *UserModel) Insert(name, email, password string) (int, error) {
 	switch email {
 	case "dupe@example.com":
-		return models.ErrDuplicateEmail
+		return 0, models.ErrDuplicateEmail
 	default:
-		return nil
+		return 4, nil
 	}
 }
 
 func (m *UserModel) Authenticate(email, password string) (int, error) {
-	if email == "alice@example.com" && password == "pa$$word" {
-		return 1, nil
+	for _, u := range []*models.User{mockUser, mockUnverifiedUser, mockTwoFactorUser} {
+		if email == u.Email && password == "pa$$word" {
+			return u.ID, nil
+		}
 	}
 	return 0, models.ErrInvalidCredentials
 }
 
 func (m *UserModel) Exists(id int) (bool, error) {
 	switch id {
-	case 1:
+	case 1, 3, 5:
 		return true, nil
 	default:
 		return false, nil
 	}
 }
+
+func (m *UserModel) Get(id int) (*models.User, error) {
+	switch id {
+	case mockUser.ID:
+		return mockUser, nil
+	case mockUnverifiedUser.ID:
+		return mockUnverifiedUser, nil
+	case mockTwoFactorUser.ID:
+		return mockTwoFactorUser, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) VerifyEmail(id int, email string) error {
+	for _, u := range []*models.User{mockUser, mockUnverifiedUser, mockTwoFactorUser} {
+		if id == u.ID && email == u.Email {
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *UserModel) UpdateName(id int, name string) error {
+	return nil
+}
+
+func (m *UserModel) UpdateEmail(id int, email string) error {
+	if email == "dupe@example.com" {
+		return models.ErrDuplicateEmail
+	}
+	return nil
+}
+
+func (m *UserModel) CheckPassword(id int, password string) error {
+	if password == "pa$$word" {
+		return nil
+	}
+	return models.ErrInvalidCredentials
+}
+
+func (m *UserModel) UpdatePassword(id int, password string) error {
+	return nil
+}
+
+func (m *UserModel) Delete(id int) error {
+	if id == mockUser.ID || id == mockUnverifiedUser.ID || id == mockTwoFactorUser.ID {
+		return nil
+	}
+	return models.ErrNoRecord
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// resetTokenLength is the number of random base62 chars in a reset token
+const resetTokenLength = 40
+
+// PasswordResetModel keeps the one time tokens emailed out to reset a
+// password. Like API tokens only a hash is stored, so the table on its own
+// can't be used to take over accounts
+type PasswordResetModel struct {
+	DB *sql.DB
+}
+
+type PasswordResetModelInterface interface {
+	Insert(email string, ttl time.Duration) (string, error)
+	Check(plaintext string) error
+	Reset(plaintext, password string) (int, error)
+}
+
+// Insert creates a reset token for the account with email that lasts for ttl,
+// returning the plaintext token. ErrNoRecord if there is no such account
+func (m *PasswordResetModel) Insert(email string, ttl time.Duration) (string, error) {
+	var userID int
+	err := m.DB.QueryRow("SELECT id FROM users WHERE email = ?", email).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+
+	plaintext, err := randomString(resetTokenLength)
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO password_resets (user_id, token_hash, created, expires)
+    VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+	_, err = m.DB.Exec(stmt, userID, hashToken(plaintext), int(ttl.Seconds()))
+	if err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+// Check returns ErrNoRecord unless plaintext is an unused, unexpired token
+func (m *PasswordResetModel) Check(plaintext string) error {
+	var exists bool
+	stmt := `SELECT EXISTS(SELECT true FROM password_resets
+    WHERE token_hash = ? AND expires > UTC_TIMESTAMP())`
+
+	err := m.DB.QueryRow(stmt, hashToken(plaintext)).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// Reset sets a new password for the account the token belongs to and returns
+// its ID. Every reset token of the account is used up, not just this one, as
+// older links in the inbox shouldn't work any more either. ErrNoRecord if the
+// token is unknown, expired or has already been used
+func (m *PasswordResetModel) Reset(plaintext, password string) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	//locked so two requests with the same token can't both use it
+	var userID int
+	stmt := `SELECT user_id FROM password_resets
+    WHERE token_hash = ? AND expires > UTC_TIMESTAMP() FOR UPDATE`
+	err = tx.QueryRow(stmt, hashToken(plaintext)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	_, err = tx.Exec("UPDATE users SET hashed_password = ? WHERE id = ?", string(hashedPassword), userID)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("DELETE FROM password_resets WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
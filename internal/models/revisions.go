@@ -0,0 +1,58 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Revision holds one saved version of a snippet, numbered from 1
+type Revision struct {
+	SnippetID int
+	Revision  int
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+// Revisions returns every saved version of a snippet, newest first
+func (m *SnippetModel) Revisions(snippetID int) ([]*Revision, error) {
+	stmt := `SELECT snippet_id, revision, title, content, created FROM snippet_revisions
+    WHERE snippet_id = ? ORDER BY revision DESC`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*Revision{}
+	for rows.Next() {
+		r := &Revision{}
+		err = rows.Scan(&r.SnippetID, &r.Revision, &r.Title, &r.Content, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetRevision returns a single version of a snippet, or ErrNoRecord if it doesn't exist
+func (m *SnippetModel) GetRevision(snippetID int, revision int) (*Revision, error) {
+	stmt := `SELECT snippet_id, revision, title, content, created FROM snippet_revisions
+    WHERE snippet_id = ? AND revision = ?`
+
+	r := &Revision{}
+	err := m.DB.QueryRow(stmt, snippetID, revision).Scan(&r.SnippetID, &r.Revision, &r.Title, &r.Content, &r.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// last_seen is only written when it is older than this, so most requests
+// just read the row
+const sessionTouchInterval = time.Minute
+
+// UserSession is one logged in browser or device. The session data itself is
+// in the sessions table kept by scs, this is what we know about it for the
+// user to look over
+type UserSession struct {
+	ID        int
+	UserID    int
+	IP        string //as of the last time it was seen
+	UserAgent string
+	Created   time.Time
+	LastSeen  time.Time
+	Expires   time.Time
+}
+
+// UserSessionModel keeps a row for each session a user is logged in with.
+// The session holds the ID of its row and is only logged in while the row is
+// there, so deleting it signs the session out wherever it is
+type UserSessionModel struct {
+	DB *sql.DB
+}
+
+type UserSessionModelInterface interface {
+	Insert(userID int, ip, userAgent string, expires time.Time) (int, error)
+	Touch(id, userID int, ip string) (bool, error)
+	ListByUser(userID int) ([]*UserSession, error)
+	Delete(id, userID int) error
+	DeleteOthers(userID, keepID int) error
+	DeleteByUser(userID int) error
+}
+
+// Insert records a new login and returns the ID for the session to keep.
+// The user's expired sessions are cleared out at the same time
+func (m *UserSessionModel) Insert(userID int, ip, userAgent string, expires time.Time) (int, error) {
+	_, err := m.DB.Exec("DELETE FROM user_sessions WHERE user_id = ? AND expires <= UTC_TIMESTAMP()", userID)
+	if err != nil {
+		return 0, err
+	}
+
+	//user agents can be any length, the start is enough to tell them apart
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	stmt := `INSERT INTO user_sessions (user_id, ip, user_agent, created, last_seen, expires)
+    VALUES(?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), ?)`
+
+	result, err := m.DB.Exec(stmt, userID, ip, userAgent, expires.UTC())
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Touch notes that the session was just used from ip. false if it has been
+// signed out or has expired, and the request shouldn't be logged in
+func (m *UserSessionModel) Touch(id, userID int, ip string) (bool, error) {
+	var lastSeen time.Time
+	var lastIP string
+	stmt := `SELECT last_seen, ip FROM user_sessions
+    WHERE id = ? AND user_id = ? AND expires > UTC_TIMESTAMP()`
+
+	err := m.DB.QueryRow(stmt, id, userID).Scan(&lastSeen, &lastIP)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if time.Since(lastSeen) > sessionTouchInterval || ip != lastIP {
+		stmt = "UPDATE user_sessions SET last_seen = UTC_TIMESTAMP(), ip = ? WHERE id = ?"
+		_, err = m.DB.Exec(stmt, ip, id)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// ListByUser returns the user's sessions that haven't expired, the most
+// recently used first
+func (m *UserSessionModel) ListByUser(userID int) ([]*UserSession, error) {
+	stmt := `SELECT id, user_id, ip, user_agent, created, last_seen, expires FROM user_sessions
+    WHERE user_id = ? AND expires > UTC_TIMESTAMP() ORDER BY last_seen DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*UserSession{}
+	for rows.Next() {
+		s := &UserSession{}
+		err = rows.Scan(&s.ID, &s.UserID, &s.IP, &s.UserAgent, &s.Created, &s.LastSeen, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Delete signs out one of the user's sessions. ErrNoRecord if they don't have
+// one with that ID
+func (m *UserSessionModel) Delete(id, userID int) error {
+	result, err := m.DB.Exec("DELETE FROM user_sessions WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// DeleteOthers signs out every session of the user except keepID
+func (m *UserSessionModel) DeleteOthers(userID, keepID int) error {
+	_, err := m.DB.Exec("DELETE FROM user_sessions WHERE user_id = ? AND id <> ?", userID, keepID)
+	return err
+}
+
+// DeleteByUser signs out every session of the user
+func (m *UserSessionModel) DeleteByUser(userID int) error {
+	_, err := m.DB.Exec("DELETE FROM user_sessions WHERE user_id = ?", userID)
+	return err
+}
@@ -0,0 +1,51 @@
+package models
+
+import "crypto/rand"
+
+// short IDs are 10 base62 chars, about 59 bits of randomness, far too many to count through
+const shortIDLength = 10
+
+const shortIDAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// newShortID returns a random URL safe ID for a snippet
+func newShortID() (string, error) {
+	return randomString(shortIDLength)
+}
+
+// randomString returns n random base62 chars from crypto/rand
+func randomString(n int) (string, error) {
+	id := make([]byte, 0, n)
+	buf := make([]byte, n*2)
+
+	for len(id) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			//bytes above 247 are thrown away so every char is equally likely (248 = 4 * 62)
+			if b >= 248 {
+				continue
+			}
+			id = append(id, shortIDAlphabet[b%62])
+			if len(id) == n {
+				break
+			}
+		}
+	}
+	return string(id), nil
+}
+
+// ValidShortID reports whether id looks like something newShortID could have made,
+// lets handlers turn away junk without a trip to the DB
+func ValidShortID(id string) bool {
+	if len(id) != shortIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !('0' <= c && c <= '9' || 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"snippetbox/internal/assert"
+	"testing"
+)
+
+func TestNewShortID(t *testing.T) {
+	// Generate a batch of IDs and make sure every one is well formed and
+	// that none of them repeat.
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		id, err := newShortID()
+		assert.NilError(t, err)
+		assert.Equal(t, ValidShortID(id), true)
+		assert.Equal(t, seen[id], false)
+		seen[id] = true
+	}
+}
+
+func TestValidShortID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{
+			name: "Valid",
+			id:   "Xk3pQ9aZ1b",
+			want: true,
+		},
+		{
+			name: "Too short",
+			id:   "Xk3pQ9aZ1",
+			want: false,
+		},
+		{
+			name: "Too long",
+			id:   "Xk3pQ9aZ1bc",
+			want: false,
+		},
+		{
+			name: "Not base62",
+			id:   "Xk3pQ9aZ1-",
+			want: false,
+		},
+		{
+			name: "Empty",
+			id:   "",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, ValidShortID(tt.id), tt.want)
+		})
+	}
+}
@@ -3,17 +3,68 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // define a snippet type to hold data for indiv snippet.
 // Fields must correspond to fields in our SQL snips
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID         int    //internal only, never shown in URLs or pages
+	ShortID    string //random public ID used in links
+	Title      string
+	Content    string
+	Created    time.Time
+	Updated    time.Time //last time the title or content changed
+	Expires    time.Time //zero for snippets that never expire
+	UserID     int       //ID of the user who created the snippet, 0 for anonymous snippets or if it no longer has an owner
+	Visibility string    //one of the Visibility constants
+	//deleted the first time it's read, see Burn
+	BurnAfterReading bool
+	//bcrypt hash of the password needed to read it, empty if there is none
+	HashedPassword []byte
+	//language used for syntax highlighting, empty to detect it from the content
+	Language string
+}
+
+// HasPassword reports whether a password is needed to read the snippet
+func (s *Snippet) HasPassword() bool {
+	return len(s.HashedPassword) > 0
+}
+
+// Who gets to see a snippet. Public ones are listed on the home page, unlisted
+// ones can be seen by anyone with the link and private ones only by their owner
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// snippetColumns lists the columns every snippet query selects, in the order scanSnippet expects them
+const snippetColumns = "id, short_id, title, content, created, updated, expires, COALESCE(user_id, 0), visibility, burn_after_reading, hashed_password, language"
+
+// unexpired is the WHERE condition matching snippets that are still live
+const unexpired = "(expires IS NULL OR expires > UTC_TIMESTAMP())"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSnippet copies the snippetColumns of a single row into a new snippet
+func scanSnippet(row rowScanner) (*Snippet, error) {
+	s := &Snippet{}
+	//expires is NULL for snippets that never expire, which leaves s.Expires as the zero time
+	var expires sql.NullTime
+	err := row.Scan(&s.ID, &s.ShortID, &s.Title, &s.Content, &s.Created, &s.Updated, &expires, &s.UserID, &s.Visibility, &s.BurnAfterReading, &s.HashedPassword, &s.Language)
+	if err != nil {
+		return nil, err
+	}
+	s.Expires = expires.Time
+	return s, nil
 }
 
 type SnippetModel struct {
@@ -21,51 +72,140 @@ type SnippetModel struct {
 } //Defines snip model to wrap a sql connection
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (*Snippet, error)
+	Insert(snippet *Snippet, password string) (string, error)
+	Get(shortID string) (*Snippet, error)
+	Burn(shortID string) (*Snippet, error)
+	Unlock(shortID string, password string) error
 	Latest() ([]*Snippet, error)
+	ListByUser(userID int) ([]*Snippet, error)
+	Update(id int, title string, content string) error
+	Delete(id int) error
+	UpdateExpiry(id int, expires time.Time) error
+	DeleteExpired(limit int) (int, error)
+	DeleteByUser(userID int) (int, error)
+	AnonymizeByUser(userID int) (int, error)
+	Revisions(snippetID int) ([]*Revision, error)
+	GetRevision(snippetID int, revision int) (*Revision, error)
 } //used in tests
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+// Insert saves a new snippet and returns the short ID it can be viewed at.
+// Title, Content, Expires, UserID, Visibility, BurnAfterReading and Language are taken from
+// snippet, a zero Expires meaning it never expires. If password isn't empty,
+// it's stored as a bcrypt hash and needed to read the snippet
+func (m *SnippetModel) Insert(snippet *Snippet, password string) (string, error) {
+	// Hashed the same way as account passwords in UserModel.Insert.
+	var hashedPassword []byte
+	if password != "" {
+		var err error
+		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(password), 12)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Short IDs are random, so on the very rare chance one is already taken
+	// just roll another.
+	for attempt := 1; ; attempt++ {
+		shortID, err := newShortID()
+		if err != nil {
+			return "", err
+		}
+
+		err = m.insert(shortID, snippet, hashedPassword)
+		if err != nil {
+			var mySQLError *mysql.MySQLError
+			if errors.As(err, &mySQLError) && mySQLError.Number == 1062 &&
+				strings.Contains(mySQLError.Message, "snippets_uc_short_id") && attempt < 5 {
+				continue
+			}
+			return "", err
+		}
+		return shortID, nil
+	}
+}
+
+func (m *SnippetModel) insert(shortID string, snippet *Snippet, hashedPassword []byte) error {
+	// The snippet and its first revision are written together in a
+	// transaction, so there is never a snippet without any history.
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once Commit has succeeded, so it is always safe to defer.
+	defer tx.Rollback()
+
 	// Write the SQL statement we want to execute. I've split it over two lines
 	// for readability (which is why it's surrounded with backquotes instead
 	// of normal double quotes).
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-    VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := `INSERT INTO snippets (short_id, title, content, created, updated, expires, user_id, visibility, burn_after_reading, hashed_password, language)
+    VALUES(?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), ?, ?, ?, ?, ?, ?)`
 	//pgsql uses $N, msql uses ?
-	// Use the Exec() method on the embedded connection pool to execute the
+	// Use the Exec() method on the transaction to execute the
 	// statement. The first parameter is the SQL statement, followed by the
-	// title, content and expiry values for the placeholder parameters. This
+	// short ID, title, content, expiry, owner, visibility, burn, password and language values for the placeholder parameters. This
 	// method returns a sql.Result type, which contains some basic
 	// information about what happened when the statement was executed.
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	result, err := tx.Exec(stmt, shortID, snippet.Title, snippet.Content, nullTime(snippet.Expires),
+		nullInt(snippet.UserID), snippet.Visibility, snippet.BurnAfterReading, string(hashedPassword), snippet.Language)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
 	// Use the LastInsertId() method on the result to get the ID of our
-	// newly inserted record in the snippets table.
+	// newly inserted record in the snippets table, needed for the revision.
 	id, err := result.LastInsertId()
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	// The ID returned has the type int64, so we convert it to an int type
-	// before returning.
-	return int(id), nil
+	stmt = `INSERT INTO snippet_revisions (snippet_id, revision, title, content, created)
+    SELECT id, 1, title, content, created FROM snippets WHERE id = ?`
+	_, err = tx.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// This returns 10 most recent snips
+// nullInt turns 0 into NULL, used for the owner of anonymous snippets
+func nullInt(i int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(i), Valid: i != 0}
+}
+
+// nullTime turns the zero time into NULL, so "never" can be stored in a DATETIME column
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// This returns 10 most recent public snips, unlisted and private ones are never listed.
+// Neither are burn after reading ones, as the first passer by would destroy them
 func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	//SQL
-	stmt := "SELECT id, title, content, created, expires FROM snippets WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10"
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE ` + unexpired + `
+    AND visibility = 'public' AND NOT burn_after_reading ORDER BY id DESC LIMIT 10`
 
 	//Use Query method to exec, returns more than one row tho!
 	rows, err := m.DB.Query(stmt)
 	if err != nil {
 		return nil, err
 	}
+	return scanSnippets(rows)
+}
+
+// ListByUser returns every unexpired snippet owned by the given user whatever its visibility, newest first
+func (m *SnippetModel) ListByUser(userID int) ([]*Snippet, error) {
+	stmt := "SELECT " + snippetColumns + " FROM snippets WHERE " + unexpired + " AND user_id = ? ORDER BY id DESC"
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSnippets(rows)
+}
 
+// scanSnippets copies each row of a snippets query into a slice, closing rows when done
+func scanSnippets(rows *sql.Rows) ([]*Snippet, error) {
 	//Ensure prop closed, after connection
 	//Always have, if not, connection is open and many things start to go wrong as you scale
 
@@ -75,10 +215,9 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 
 	//loop thru the resultset
 	for rows.Next() {
-		s := &Snippet{}
 		//we use rows.scan to copy values from each field in the row to a new object we created.
 		//new objects must be pointers
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		s, err := scanSnippet(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -88,23 +227,22 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 
 	//When loop finished, call rows.err to get any err if any
 	//Never assume success with Databases, ensure it
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 	return snippets, nil
 	//If ok, return snippets slice
 }
 
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
+// Get looks up an unexpired snippet by its short ID
+func (m *SnippetModel) Get(shortID string) (*Snippet, error) {
 	//SQL
-	stmt := "SELECT id, title, content, created, expires FROM snippets WHERE expires > UTC_TIMESTAMP() AND id = ?"
+	stmt := "SELECT " + snippetColumns + " FROM snippets WHERE " + unexpired + " AND short_id = ?"
 	//use of query row instead on conn pool as we only want a single row result
-	row := m.DB.QueryRow(stmt, id)
-	s := &Snippet{}
-	//Pointer to a new zeroed snippet struct
+	row := m.DB.QueryRow(stmt, shortID)
 
-	//Use row.Scan() to copy values from each field in row to snip struct
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	//Use row.Scan() to copy values from each field in row to a new snip struct
+	s, err := scanSnippet(row)
 	if err != nil {
 		//If Query returns no rows, scan returns a ErrNowRows error.
 		//We should use errors.IS function to check for that err
@@ -118,6 +256,201 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 	return s, nil
 }
 
+// Burn returns a burn after reading snippet and deletes it in the same transaction.
+// The row is locked while we read it, so if two requests race only one gets the
+// snippet and the other gets ErrNoRecord
+func (m *SnippetModel) Burn(shortID string) (*Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+    WHERE ` + unexpired + ` AND short_id = ? AND burn_after_reading FOR UPDATE`
+	s, err := scanSnippet(tx.QueryRow(stmt, shortID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	//revisions go with it thanks to ON DELETE CASCADE
+	_, err = tx.Exec("DELETE FROM snippets WHERE id = ?", s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Unlock checks password against a protected snippet, returning ErrInvalidCredentials if it's wrong
+func (m *SnippetModel) Unlock(shortID string, password string) error {
+	var hashedPassword []byte
+	stmt := "SELECT hashed_password FROM snippets WHERE " + unexpired + " AND short_id = ?"
+
+	err := m.DB.QueryRow(stmt, shortID).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+	//same check as UserModel.Authenticate
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+	return nil
+}
+
+// Update replaces the title and content of an existing snippet, saving them
+// as a new revision so the earlier versions are kept
+func (m *SnippetModel) Update(id int, title string, content string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the snippet row so two edits at once can't both pick the same
+	// revision number.
+	var locked int
+	err = tx.QueryRow("SELECT id FROM snippets WHERE id = ? FOR UPDATE", id).Scan(&locked)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	var latest int
+	stmt := "SELECT COALESCE(MAX(revision), 0) FROM snippet_revisions WHERE snippet_id = ?"
+	err = tx.QueryRow(stmt, id).Scan(&latest)
+	if err != nil {
+		return err
+	}
+
+	// Snippets created before revisions existed have no history yet, so keep
+	// what they hold now as revision 1 before it gets overwritten.
+	if latest == 0 {
+		stmt = `INSERT INTO snippet_revisions (snippet_id, revision, title, content, created)
+    SELECT id, 1, title, content, created FROM snippets WHERE id = ?`
+		_, err = tx.Exec(stmt, id)
+		if err != nil {
+			return err
+		}
+		latest = 1
+	}
+
+	stmt = `INSERT INTO snippet_revisions (snippet_id, revision, title, content, created)
+    VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+	_, err = tx.Exec(stmt, id, latest+1, title, content)
+	if err != nil {
+		return err
+	}
+
+	stmt = "UPDATE snippets SET title = ?, content = ?, updated = UTC_TIMESTAMP() WHERE id = ?"
+	_, err = tx.Exec(stmt, title, content, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateExpiry moves the expiry of a snippet, a zero expires means it never expires
+func (m *SnippetModel) UpdateExpiry(id int, expires time.Time) error {
+	stmt := "UPDATE snippets SET expires = ? WHERE id = ?"
+
+	_, err := m.DB.Exec(stmt, nullTime(expires), id)
+	return err
+}
+
+// Delete removes a snippet, returning ErrNoRecord if there was nothing to delete
+func (m *SnippetModel) Delete(id int) error {
+	stmt := "DELETE FROM snippets WHERE id = ?"
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+	//RowsAffected tells us if the snippet existed in the first place
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// DeleteByUser removes every snippet of a user along with their revisions,
+// returning how many went. Used when an account is deleted
+func (m *SnippetModel) DeleteByUser(userID int) (int, error) {
+	result, err := m.DB.Exec("DELETE FROM snippets WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// AnonymizeByUser detaches the snippets of a user from their account so they
+// stay up without an owner, returning how many were kept. Private snippets are
+// deleted instead, as with no owner nobody could ever read them again
+func (m *SnippetModel) AnonymizeByUser(userID int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM snippets WHERE user_id = ? AND visibility = 'private'", userID)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec("UPDATE snippets SET user_id = NULL WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// DeleteExpired removes up to limit expired snippets and their revisions, returning how many went.
+// Keeping each call bounded means the table is never locked for long
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	stmt := "DELETE FROM snippets WHERE expires <= UTC_TIMESTAMP() ORDER BY expires LIMIT ?"
+
+	result, err := m.DB.Exec(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(rows), nil
+}
+
 //Were adding this new snippet struct to represent data for snippet along with our
 //snippet model type - Need to add to main.go and inject it as a dependecies
 //cuz of how this is set, db logic is not around our handlers whihc means
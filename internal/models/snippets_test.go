@@ -0,0 +1,50 @@
+package models
+
+import (
+	"snippetbox/internal/assert"
+	"testing"
+)
+
+func TestSnippetModelListByUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  int
+		wantIDs []string
+	}{
+		{
+			// Newest first, private ones included but not expired ones.
+			name:    "Owner",
+			userID:  1,
+			wantIDs: []string{"Pv5tH8kL2q", "Xk3pQ9aZ1b"},
+		},
+		{
+			name:   "No snippets",
+			userID: 2,
+		},
+		{
+			// Anonymous snippets have no owner, they aren't user 0's.
+			name:   "Zero ID",
+			userID: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			m := SnippetModel{DB: db}
+
+			snippets, err := m.ListByUser(tt.userID)
+			assert.NilError(t, err)
+
+			ids := []string{}
+			for _, s := range snippets {
+				assert.Equal(t, s.UserID, tt.userID)
+				ids = append(ids, s.ShortID)
+			}
+			assert.Equal(t, len(ids), len(tt.wantIDs))
+			for i := range tt.wantIDs {
+				assert.Equal(t, ids[i], tt.wantIDs[i])
+			}
+		})
+	}
+}
@@ -1,26 +1,121 @@
+CREATE TABLE users (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created DATETIME NOT NULL,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
+
 CREATE TABLE snippets (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    short_id CHAR(10) CHARACTER SET ascii COLLATE ascii_bin NOT NULL,
     title VARCHAR(100) NOT NULL,
     content TEXT NOT NULL,
     created DATETIME NOT NULL,
-    expires DATETIME NOT NULL
+    updated DATETIME NOT NULL,
+    expires DATETIME,
+    user_id INTEGER,
+    visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'public',
+    burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+    hashed_password CHAR(60) NOT NULL DEFAULT '',
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    CONSTRAINT snippets_uc_short_id UNIQUE (short_id),
+    CONSTRAINT snippets_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
 );
 
 CREATE INDEX idx_snippets_created ON snippets(created);
 
-CREATE TABLE users (
+CREATE INDEX idx_snippets_expires ON snippets(expires);
+
+CREATE TABLE snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT snippet_revisions_fk_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+    CONSTRAINT snippet_revisions_uc_revision UNIQUE (snippet_id, revision)
+);
+
+CREATE TABLE api_tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token_hash BINARY(32) NOT NULL,
+    scopes SET('read', 'write', 'delete') NOT NULL,
+    created DATETIME NOT NULL,
+    last_used DATETIME,
+    expires DATETIME,
+    CONSTRAINT api_tokens_uc_token_hash UNIQUE (token_hash),
+    CONSTRAINT api_tokens_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE password_resets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash BINARY(32) NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    CONSTRAINT password_resets_uc_token_hash UNIQUE (token_hash),
+    CONSTRAINT password_resets_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE user_totp (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    last_counter BIGINT NOT NULL DEFAULT 0,
+    created DATETIME NOT NULL,
+    CONSTRAINT user_totp_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash BINARY(32) NOT NULL,
+    CONSTRAINT recovery_codes_uc_code_hash UNIQUE (user_id, code_hash),
+    CONSTRAINT recovery_codes_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE user_sessions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL,
+    last_seen DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    CONSTRAINT user_sessions_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_sessions_user ON user_sessions(user_id, expires);
+
+CREATE TABLE login_attempts (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
-    name VARCHAR(255) NOT NULL,
     email VARCHAR(255) NOT NULL,
-    hashed_password CHAR(60) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    outcome ENUM('success', 'failure', 'blocked') NOT NULL,
     created DATETIME NOT NULL
 );
 
-ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
+CREATE INDEX idx_login_attempts_email ON login_attempts(email, created);
+
+CREATE INDEX idx_login_attempts_ip ON login_attempts(ip, created);
 
-INSERT INTO users (name, email, hashed_password, created) VALUES (
+INSERT INTO users (name, email, hashed_password, created, email_verified) VALUES (
     'Alice Jones',
     'alice@example.com',
     '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
-    '2022-01-01 10:00:00'
-);
\ No newline at end of file
+    '2022-01-01 10:00:00',
+    TRUE
+);
+
+INSERT INTO snippets (short_id, title, content, created, updated, expires, user_id, visibility) VALUES
+    ('Xk3pQ9aZ1b', 'An old silent pond', 'An old silent pond...', '2022-01-01 10:00:00', '2022-01-01 10:00:00', NULL, 1, 'public'),
+    ('Pv5tH8kL2q', 'Over the wintry', 'Over the wintry forest...', '2022-01-02 10:00:00', '2022-01-02 10:00:00', '2099-01-01 10:00:00', 1, 'private'),
+    ('Ex7pR3dW9c', 'First autumn morning', 'First autumn morning...', '2022-01-03 10:00:00', '2022-01-03 10:00:00', '2022-01-04 10:00:00', 1, 'public'),
+    ('An0nY8mS4z', 'The light of a candle', 'The light of a candle...', '2022-01-04 10:00:00', '2022-01-04 10:00:00', NULL, NULL, 'unlisted');
@@ -1,3 +1,17 @@
-DROP TABLE users;
+DROP TABLE user_sessions;
 
-DROP TABLE snippets;
\ No newline at end of file
+DROP TABLE recovery_codes;
+
+DROP TABLE user_totp;
+
+DROP TABLE password_resets;
+
+DROP TABLE login_attempts;
+
+DROP TABLE api_tokens;
+
+DROP TABLE snippet_revisions;
+
+DROP TABLE snippets;
+
+DROP TABLE users;
\ No newline at end of file
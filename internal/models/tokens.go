@@ -0,0 +1,182 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// What an API token is allowed to do
+const (
+	ScopeRead   = "read"   //read private snippets of the owner
+	ScopeWrite  = "write"  //create and edit snippets
+	ScopeDelete = "delete" //delete snippets
+)
+
+// Scopes lists every scope, in the order they are shown
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeDelete}
+
+// tokenPrefix marks API tokens so they are easy to spot, e.g. by secret scanners
+const tokenPrefix = "sb_"
+
+// tokenLength is the number of random base62 chars after the prefix, about 238 bits
+const tokenLength = 40
+
+// Token is a personal API token. Only a hash of the token itself is stored,
+// the plaintext is shown to the user once when it is created
+type Token struct {
+	ID       int
+	UserID   int
+	Name     string
+	Scopes   []string
+	Created  time.Time
+	LastUsed time.Time //zero if it has never been used
+	Expires  time.Time //zero for tokens that never expire
+}
+
+// HasScope reports whether the token was given scope
+func (t *Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+type TokenModelInterface interface {
+	Insert(userID int, name string, scopes []string, expires time.Time) (string, error)
+	Authenticate(plaintext string) (*Token, error)
+	ListByUser(userID int) ([]*Token, error)
+	Delete(id int, userID int) error
+	DeleteByUser(userID int) error
+}
+
+// hashToken is what gets stored in place of the token. Tokens are long and
+// random so a plain SHA-256 is enough, unlike passwords there is nothing to guess
+func hashToken(plaintext string) []byte {
+	sum := sha256.Sum256([]byte(plaintext))
+	return sum[:]
+}
+
+// Insert creates a token for the user, returning the plaintext token. A zero
+// expires means it never expires
+func (m *TokenModel) Insert(userID int, name string, scopes []string, expires time.Time) (string, error) {
+	random, err := randomString(tokenLength)
+	if err != nil {
+		return "", err
+	}
+	plaintext := tokenPrefix + random
+
+	stmt := `INSERT INTO api_tokens (user_id, name, token_hash, scopes, created, expires)
+    VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+	//scopes is a SET column, which takes its members comma separated
+	_, err = m.DB.Exec(stmt, userID, name, hashToken(plaintext), strings.Join(scopes, ","), nullTime(expires))
+	if err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+// Authenticate looks up an unexpired token, returning ErrInvalidCredentials if
+// there is no such token. It also records that the token has been used
+func (m *TokenModel) Authenticate(plaintext string) (*Token, error) {
+	if !strings.HasPrefix(plaintext, tokenPrefix) {
+		return nil, ErrInvalidCredentials
+	}
+
+	stmt := `SELECT id, user_id, name, scopes, created, last_used, expires FROM api_tokens
+    WHERE token_hash = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP())`
+
+	t, err := scanToken(m.DB.QueryRow(stmt, hashToken(plaintext)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	// last_used only needs to be roughly right, so don't write it on every
+	// request of a busy client
+	stmt = `UPDATE api_tokens SET last_used = UTC_TIMESTAMP()
+    WHERE id = ? AND (last_used IS NULL OR last_used < UTC_TIMESTAMP() - INTERVAL 1 MINUTE)`
+	_, err = m.DB.Exec(stmt, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ListByUser returns every token of a user, expired ones included, newest first
+func (m *TokenModel) ListByUser(userID int) ([]*Token, error) {
+	stmt := `SELECT id, user_id, name, scopes, created, last_used, expires FROM api_tokens
+    WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*Token{}
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Delete revokes a token. The user ID has to match too, so nobody can revoke
+// somebody else's token by guessing IDs. ErrNoRecord if nothing was deleted
+func (m *TokenModel) Delete(id int, userID int) error {
+	stmt := "DELETE FROM api_tokens WHERE id = ? AND user_id = ?"
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// DeleteByUser revokes every token of the user
+func (m *TokenModel) DeleteByUser(userID int) error {
+	_, err := m.DB.Exec("DELETE FROM api_tokens WHERE user_id = ?", userID)
+	return err
+}
+
+// scanToken reads a token row selected in the order Authenticate and ListByUser use
+func scanToken(row rowScanner) (*Token, error) {
+	t := &Token{}
+	var scopes string
+	var lastUsed, expires sql.NullTime
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created, &lastUsed, &expires)
+	if err != nil {
+		return nil, err
+	}
+	if scopes != "" {
+		t.Scopes = strings.Split(scopes, ",")
+	}
+	t.LastUsed = lastUsed.Time
+	t.Expires = expires.Time
+	return t, nil
+}
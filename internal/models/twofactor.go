@@ -0,0 +1,203 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+)
+
+// how many recovery codes a user gets when they turn on two-factor logins
+const recoveryCodeCount = 10
+
+// TwoFactor is a user's authenticator app set up. It isn't Enabled until a
+// first code from the app has been checked, so a half finished set up never
+// locks anyone out
+type TwoFactor struct {
+	UserID      int
+	Secret      string //base32 TOTP secret
+	Enabled     bool
+	LastCounter int64 //time step of the last code used, older ones are refused
+	Created     time.Time
+}
+
+// TwoFactorModel keeps TOTP secrets and recovery codes. The secret has to be
+// kept as is to work out codes from it, but recovery codes are only stored
+// hashed like API tokens
+type TwoFactorModel struct {
+	DB *sql.DB
+}
+
+type TwoFactorModelInterface interface {
+	Get(userID int) (*TwoFactor, error)
+	Begin(userID int, secret string) error
+	Enable(userID int, counter int64) ([]string, error)
+	Disable(userID int) error
+	UseCounter(userID int, counter int64) error
+	UseRecoveryCode(userID int, code string) error
+	RecoveryCodesLeft(userID int) (int, error)
+}
+
+// Get returns the user's set up, ErrNoRecord if they haven't started one
+func (m *TwoFactorModel) Get(userID int) (*TwoFactor, error) {
+	stmt := `SELECT user_id, secret, enabled, last_counter, created FROM user_totp
+    WHERE user_id = ?`
+
+	tf := &TwoFactor{}
+	err := m.DB.QueryRow(stmt, userID).Scan(&tf.UserID, &tf.Secret, &tf.Enabled, &tf.LastCounter, &tf.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	return tf, nil
+}
+
+// Begin starts setting up two-factor logins with secret, replacing any set up
+// that wasn't finished. An enabled one is left alone
+func (m *TwoFactorModel) Begin(userID int, secret string) error {
+	stmt := `INSERT INTO user_totp (user_id, secret, enabled, last_counter, created)
+    VALUES(?, ?, FALSE, 0, UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE
+        secret = IF(enabled, secret, VALUES(secret)),
+        created = IF(enabled, created, VALUES(created))`
+
+	_, err := m.DB.Exec(stmt, userID, secret)
+	return err
+}
+
+// Enable turns on two-factor logins once the first code, from time step
+// counter, has been checked. It returns the plaintext recovery codes, which
+// can't be got at again. ErrNoRecord if there is no set up waiting to be enabled
+func (m *TwoFactorModel) Enable(userID int, counter int64) ([]string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE user_totp SET enabled = TRUE, last_counter = ?
+    WHERE user_id = ? AND NOT enabled`
+	result, err := tx.Exec(stmt, counter, userID)
+	if err != nil {
+		return nil, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrNoRecord
+	}
+
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES(?, ?)",
+			userID, hashToken(normalizeRecoveryCode(codes[i])))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable turns two-factor logins off and throws away the secret and any
+// recovery codes left
+func (m *TwoFactorModel) Disable(userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM user_totp WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseCounter records that a code from time step counter has been used to log
+// in. ErrCodeUsed if it, or a later one, already has been, so a code seen over
+// someone's shoulder can't be used again
+func (m *TwoFactorModel) UseCounter(userID int, counter int64) error {
+	stmt := `UPDATE user_totp SET last_counter = ?
+    WHERE user_id = ? AND enabled AND last_counter < ?`
+
+	result, err := m.DB.Exec(stmt, counter, userID, counter)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCodeUsed
+	}
+	return nil
+}
+
+// UseRecoveryCode uses up one of the user's recovery codes. ErrNoRecord if it
+// isn't one of theirs or has been used before
+func (m *TwoFactorModel) UseRecoveryCode(userID int, code string) error {
+	stmt := "DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?"
+
+	result, err := m.DB.Exec(stmt, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+func (m *TwoFactorModel) RecoveryCodesLeft(userID int) (int, error) {
+	var n int
+	err := m.DB.QueryRow("SELECT COUNT(*) FROM recovery_codes WHERE user_id = ?", userID).Scan(&n)
+	return n, err
+}
+
+// recovery codes are 80 random bits as lower case base32, split up into
+// groups of 4 to make them easier to copy down, e.g. "abcd-efgh-ijkl-mnop"
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	s := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16], nil
+}
+
+// codes are hashed without the dashes and in lower case, so they can be
+// typed in either way
+func normalizeRecoveryCode(code string) string {
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return strings.ToLower(code)
+}
@@ -17,6 +17,7 @@ type User struct {
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	EmailVerified  bool //set once the link emailed on signup has been followed
 }
 
 // create new usermodel with wrapped DB connection pool
@@ -25,22 +26,29 @@ type UserModel struct {
 }
 
 type UserModelInterface interface {
-	Insert(name, email, password string) error
+	Insert(name, email, password string) (int, error)
 	Authenticate(email, password string) (int, error)
 	Exists(id int) (bool, error)
+	Get(id int) (*User, error)
+	VerifyEmail(id int, email string) error
+	UpdateName(id int, name string) error
+	UpdateEmail(id int, email string) error
+	CheckPassword(id int, password string) error
+	UpdatePassword(id int, password string) error
+	Delete(id int) error
 }
 
-// use insert method to add new record to users table
-func (m *UserModel) Insert(name, email, password string) error {
+// use insert method to add new record to users table, returning the new user's ID
+func (m *UserModel) Insert(name, email, password string) (int, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	stmt := `INSERT INTO users (name, email, hashed_password, created)
 	VALUES(?, ?, ?, UTC_TIMESTAMP())`
 
 	//use Exec() method to insert user details and hashed password into users table
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	result, err := m.DB.Exec(stmt, name, email, string(hashedPassword))
 	if err != nil {
 		//if this returns an error, we use errors.As func to check if
 		//its a mysql error. If it does, then error assigned out to mysqlerr var
@@ -48,12 +56,16 @@ func (m *UserModel) Insert(name, email, password string) error {
 		var mySQLError *mysql.MySQLError
 		if errors.As(err, &mySQLError) {
 			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
+				return 0, ErrDuplicateEmail
 			}
 		}
-		return err
+		return 0, err
 	}
-	return nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
 }
 
 // Auth method to verify if user exists with Email/pass, return userID if do
@@ -93,3 +105,119 @@ func (m *UserModel) Exists(id int) (bool, error) {
 	//return true if user exists
 	return exists, err
 }
+
+// Get returns a user by ID, ErrNoRecord if there is no such user
+func (m *UserModel) Get(id int) (*User, error) {
+	u := &User{}
+	stmt := "SELECT id, name, email, created, email_verified FROM users WHERE id = ?"
+	err := m.DB.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.EmailVerified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+// VerifyEmail marks the email of a user as verified. The email has to still be
+// the one the link was sent to, ErrNoRecord if it isn't
+func (m *UserModel) VerifyEmail(id int, email string) error {
+	stmt := "UPDATE users SET email_verified = TRUE WHERE id = ? AND email = ?"
+	result, err := m.DB.Exec(stmt, id, email)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	//MySQL doesn't count rows that were already verified as affected
+	var exists bool
+	stmt = "SELECT EXISTS(SELECT true FROM users WHERE id = ? AND email = ?)"
+	err = m.DB.QueryRow(stmt, id, email).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// UpdateName changes the display name of a user
+func (m *UserModel) UpdateName(id int, name string) error {
+	_, err := m.DB.Exec("UPDATE users SET name = ? WHERE id = ?", name, id)
+	return err
+}
+
+// UpdateEmail changes the email of a user, who has to verify the new one.
+// ErrDuplicateEmail if another account already has it
+func (m *UserModel) UpdateEmail(id int, email string) error {
+	stmt := "UPDATE users SET email = ?, email_verified = FALSE WHERE id = ?"
+	_, err := m.DB.Exec(stmt, email, id)
+	if err != nil {
+		//same check as Insert
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
+				return ErrDuplicateEmail
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// CheckPassword returns ErrInvalidCredentials unless password is the user's
+// current one, for settings that need it confirmed before they change
+func (m *UserModel) CheckPassword(id int, password string) error {
+	var hashedPassword []byte
+	err := m.DB.QueryRow("SELECT hashed_password FROM users WHERE id = ?", id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdatePassword sets a new password for a user, hashed the same way as Insert
+func (m *UserModel) UpdatePassword(id int, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+	_, err = m.DB.Exec("UPDATE users SET hashed_password = ? WHERE id = ?", string(hashedPassword), id)
+	return err
+}
+
+// Delete removes a user. Their API tokens and reset links go with them, any
+// snippets left are kept without an owner, see SnippetModel.AnonymizeByUser.
+// ErrNoRecord if there was no such user
+func (m *UserModel) Delete(id int) error {
+	result, err := m.DB.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package totp
+
+import (
+	"snippetbox/internal/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the SHA1 key from the test vectors in RFC 4226 and RFC 6238
+var rfcKey = []byte("12345678901234567890")
+
+func TestHOTP(t *testing.T) {
+	//RFC 4226 appendix D
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		assert.Equal(t, hotp(rfcKey, uint64(counter), 6), code)
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	//RFC 6238 appendix B, which uses 8 digit codes
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1111111111, want: "14050471"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 2000000000, want: "69279037"},
+		{unix: 20000000000, want: "65353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			counter := Counter(time.Unix(tt.unix, 0))
+			assert.Equal(t, hotp(rfcKey, uint64(counter), 8), tt.want)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := encoding.EncodeToString(rfcKey)
+	now := time.Unix(1111111111, 0)
+	code, err := Code(secret, now)
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		at     time.Time
+		wantOK bool
+	}{
+		{name: "Now", secret: secret, code: code, at: now, wantOK: true},
+		{name: "One step late", secret: secret, code: code, at: now.Add(30 * time.Second), wantOK: true},
+		{name: "One step early", secret: secret, code: code, at: now.Add(-30 * time.Second), wantOK: true},
+		{name: "Too late", secret: secret, code: code, at: now.Add(90 * time.Second)},
+		{name: "Lower case secret", secret: strings.ToLower(secret), code: code, at: now, wantOK: true},
+		{name: "Spaced code", secret: secret, code: code[:3] + " " + code[3:], at: now, wantOK: true},
+		{name: "Wrong code", secret: secret, code: "000000", at: now},
+		{name: "Short code", secret: secret, code: code[:5], at: now},
+		{name: "Bad secret", secret: "not base32!", code: code, at: now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, ok := Validate(tt.secret, tt.code, tt.at)
+			assert.Equal(t, ok, tt.wantOK)
+			if ok {
+				assert.Equal(t, counter, Counter(now))
+			}
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("JBSWY3DPEHPK3PXP", "Snippetbox", "alice@example.com")
+	assert.Equal(t, uri, "otpauth://totp/Snippetbox:alice@example.com?algorithm=SHA1&digits=6&issuer=Snippetbox&period=30&secret=JBSWY3DPEHPK3PXP")
+}
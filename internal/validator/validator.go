@@ -54,6 +54,12 @@ func MaxChars(value string, n int) bool {
 	return utf8.RuneCountInString(value) <= n
 }
 
+// MaxBytes() returns true if value is no more than n bytes long, for limits
+// that are in bytes rather than chars
+func MaxBytes(value string, n int) bool {
+	return len(value) <= n
+}
+
 // trying out Generics here, true if value of type T = permittedvalues param
 // permmited returns true if value is in a list of allowed integers
 func PermittedValue[T comparable](value T, permittedValues ...T) bool {